@@ -8,6 +8,8 @@ import (
 	"github.com/wmalik/ogit/internal/browser"
 	"github.com/wmalik/ogit/internal/bulkclone"
 	"github.com/wmalik/ogit/internal/clear"
+	"github.com/wmalik/ogit/internal/doctor"
+	"github.com/wmalik/ogit/internal/history"
 	"github.com/wmalik/ogit/internal/repocommands"
 
 	"github.com/urfave/cli/v2"
@@ -35,8 +37,35 @@ func main() {
 			{
 				Name:  "fetch",
 				Usage: "Fetch all repository metadata from provider APIs (e.g. GitHub/GitLab)",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "full",
+						Usage: "fetch all repositories instead of the ones updated since the last fetch",
+					},
+					&cli.BoolFlag{
+						Name:  "diff",
+						Usage: "print the repositories added, removed, renamed or updated by the fetch",
+					},
+				},
 				Action: func(c *cli.Context) error {
-					if err := browser.HandleCommandFetch(); err != nil {
+					if err := browser.HandleCommandFetch(c.Bool("full"), c.Bool("diff")); err != nil {
+						log.Fatalln(err)
+					}
+					return nil
+				},
+			},
+			{
+				Name:  "history",
+				Usage: "Show the repositories added, removed, renamed or updated by the last fetches",
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:  "limit",
+						Usage: "number of fetches to show",
+						Value: 10,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if err := history.HandleCommandDefault(c.Context, c.Int("limit")); err != nil {
 						log.Fatalln(err)
 					}
 					return nil
@@ -63,6 +92,16 @@ func main() {
 					return nil
 				},
 			},
+			{
+				Name:  "doctor",
+				Usage: "Show where the API token of each account is read from",
+				Action: func(c *cli.Context) error {
+					if err := doctor.HandleCommandDefault(c.Context); err != nil {
+						log.Fatalln(err)
+					}
+					return nil
+				},
+			},
 			{
 				Name:  "clear",
 				Usage: "Clear all local repository metadata (not the repository contents)",
@@ -1,22 +1,27 @@
 package browser
 
 import (
+	"bufio"
 	"context"
+	"fmt"
+	"io"
 	"log"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/wmalik/ogit/internal/db"
 	"github.com/wmalik/ogit/internal/gitconfig"
 	"github.com/wmalik/ogit/internal/gitutils"
+	"github.com/wmalik/ogit/internal/history"
 	"github.com/wmalik/ogit/internal/shell"
 	"github.com/wmalik/ogit/internal/sync"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
 
-func HandleCommandFetch() error {
+func HandleCommandFetch(full bool, diff bool) error {
 	ctx := context.Background()
 	gitConf, err := gitconfig.ReadGitConfig()
 	if err != nil {
@@ -36,8 +41,53 @@ func HandleCommandFetch() error {
 		log.Fatalln(err)
 	}
 
-	if err := sync.Sync(ctx, gitConf); err != nil {
-		log.Fatalln(err)
+	// the repositories which were fetched are stored even if fetching the
+	// repositories of some owners failed
+	result, fetchErr := sync.Sync(ctx, gitConf, full)
+	if diff {
+		history.PrintRun(os.Stdout, result.Run)
+	}
+	if err := offerToMoveClones(gitConf.StoragePath(), result.Renames, os.Stdin); err != nil {
+		return err
+	}
+
+	return fetchErr
+}
+
+// offerToMoveClones asks whether to move the local clones of the renamed
+// repositories to their new path, and moves the ones confirmed on in
+func offerToMoveClones(storagePath string, renames []db.Rename, in io.Reader) error {
+	reader := bufio.NewReader(in)
+	for _, rename := range renames {
+		oldPath := path.Join(storagePath, rename.Provider, rename.OldOwner, rename.OldName)
+		newPath := path.Join(storagePath, rename.Provider, rename.Owner, rename.Name)
+		if cloned, err := gitutils.Cloned(oldPath); err != nil || !cloned {
+			continue
+		}
+
+		if _, err := os.Stat(newPath); err == nil {
+			log.Printf("not moving %s, as %s already exists", oldPath, newPath)
+			continue
+		}
+
+		fmt.Printf("Move the clone of %s/%s to %s? [y/N] ", rename.OldOwner, rename.OldName, newPath)
+		answer, err := reader.ReadString('\n')
+		if err == io.EOF {
+			fmt.Println()
+		} else if err != nil {
+			return err
+		}
+		if strings.ToLower(strings.TrimSpace(answer)) != "y" {
+			continue
+		}
+
+		if err := os.MkdirAll(path.Dir(newPath), os.ModePerm); err != nil {
+			return err
+		}
+		if err := os.Rename(oldPath, newPath); err != nil {
+			return err
+		}
+		log.Printf("moved %s to %s", oldPath, newPath)
 	}
 
 	return nil
@@ -74,7 +124,16 @@ func HandleCommandDefault() error {
 	}
 	defer f.Close()
 
+	rateLimits, err := localDB.SelectRateLimits(ctx)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
 	model := NewModelWithItems(repos, gitConf.StoragePath(), gu)
+	model.localDB = localDB
+	if len(rateLimits) > 0 {
+		model.bottomStatusBar = rateLimitStatus(rateLimits)
+	}
 	for {
 		if err := tea.NewProgram(model, tea.WithAltScreen()).Start(); err != nil {
 			log.Fatalln(err)
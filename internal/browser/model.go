@@ -1,8 +1,11 @@
 package browser
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/wmalik/ogit/internal/db"
@@ -11,12 +14,17 @@ import (
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
 )
 
 // The state of browser
 type model struct {
 	// the list of repositories
 	list list.Model
+	// all repositories, including the hidden ones
+	items []list.Item
+	// whether the repositories fetched only because they are starred are shown
+	showStarred bool
 	// list of organisations or users (currently only public users or organisations)
 	orgs []string
 	// the path on disk where repositories should be cloned
@@ -30,6 +38,9 @@ type model struct {
 
 	gu *gitutils.GitUtils
 	rs *service.RepositoryService
+	// the local database, in which the viewed repositories are unmarked as
+	// new
+	localDB *db.Database
 }
 
 func NewModelWithItems(repos []db.Repository, storagePath string, gu *gitutils.GitUtils) *model {
@@ -44,12 +55,30 @@ func NewModelWithItems(repos []db.Repository, storagePath string, gu *gitutils.G
 
 	return &model{
 		list:            m,
+		items:           listItems,
+		showStarred:     true,
 		storagePath:     storagePath,
 		bottomStatusBar: "-",
 		gu:              gu,
 	}
 }
 
+// rateLimitStatus returns the API quota left on the providers after the last
+// fetch e.g. "github: 4990/5000 API calls left (resets 3:04PM)"
+func rateLimitStatus(limits []db.RateLimit) string {
+	status := []string{}
+	for _, limit := range limits {
+		status = append(status, fmt.Sprintf("%s: %d/%d API calls left (resets %s)",
+			limit.Provider,
+			limit.Remaining,
+			limit.Quota,
+			limit.ResetAt.Local().Format(time.Kitchen),
+		))
+	}
+
+	return strings.Join(status, " | ")
+}
+
 func availableKeyBindingsCB() []key.Binding {
 	return []key.Binding{
 		key.NewBinding(
@@ -76,9 +105,47 @@ func availableKeyBindingsCB() []key.Binding {
 			key.WithKeys("p"),
 			key.WithHelp("p", "pulls"),
 		),
+		key.NewBinding(
+			key.WithKeys("s"),
+			key.WithHelp("s", "toggle starred"),
+		),
 	}
 }
 
+// toggleStarred shows or hides the repositories fetched only because they are
+// starred
+func (m *model) toggleStarred() tea.Cmd {
+	m.showStarred = !m.showStarred
+	if m.showStarred {
+		return m.list.SetItems(m.items)
+	}
+
+	items := []list.Item{}
+	for _, item := range m.items {
+		if !item.(repoItem).Repository.Starred {
+			items = append(items, item)
+		}
+	}
+	return m.list.SetItems(items)
+}
+
+// setItem replaces the item of the same repository in the list, and in the
+// items restored by toggleStarred
+func (m *model) setItem(item repoItem) tea.Cmd {
+	for i, existing := range m.items {
+		if existing.(repoItem).Repository.ID == item.Repository.ID {
+			m.items[i] = item
+		}
+	}
+
+	for i, existing := range m.list.Items() {
+		if existing.(repoItem).Repository.ID == item.Repository.ID {
+			return m.list.SetItem(i, item)
+		}
+	}
+	return nil
+}
+
 func toItems(repos []db.Repository, storagePath string) []list.Item {
 	items := make([]list.Item, len(repos))
 
@@ -105,3 +172,20 @@ func sortItemsCloned(items []list.Item) []list.Item {
 	})
 	return items
 }
+
+// markViewed unmarks the selected repository as new, as it is being viewed
+func (m *model) markViewed() tea.Cmd {
+	selected, ok := m.list.SelectedItem().(repoItem)
+	if !ok || !selected.Repository.New || m.localDB == nil {
+		return nil
+	}
+
+	selected.Repository.New = false
+	id := selected.Repository.ID
+	return func() tea.Msg {
+		if err := m.localDB.MarkRepositoryViewed(context.Background(), id); err != nil {
+			log.Println(err)
+		}
+		return nil
+	}
+}
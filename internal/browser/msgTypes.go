@@ -7,6 +7,5 @@ type updateBottomStatusBarMsg string
 type openURLMsg string
 
 type cloneRepoMsg struct {
-	repo  repoItem
-	index int
+	repo repoItem
 }
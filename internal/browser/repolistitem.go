@@ -8,6 +8,7 @@ import (
 
 	"github.com/wmalik/ogit/internal/db"
 	"github.com/wmalik/ogit/internal/gitutils"
+	"github.com/wmalik/ogit/upstream"
 )
 
 type repoItem struct {
@@ -22,7 +23,26 @@ func newRepoItem(repo *db.Repository, storageBasePath string) repoItem {
 	}
 }
 
-func (i repoItem) Title() string       { return i.Repository.Title }
+// Title returns the title of the repository, marked with the access of the
+// user if it is known, as gone if it is missing upstream, and as new if it was
+// added by the last fetches and not viewed yet
+func (i repoItem) Title() string {
+	title := i.Repository.Title
+	switch i.Repository.Access {
+	case upstream.AccessWrite:
+		title += accessStyle.Render(" [rw]")
+	case upstream.AccessRead:
+		title += accessStyle.Render(" [ro]")
+	}
+	if i.Repository.Gone {
+		title += accessStyle.Render(" [gone]")
+	}
+	if i.Repository.New {
+		title += newStyle.Render(" [new]")
+	}
+	return title
+}
+
 func (i repoItem) Description() string { return i.Repository.Description }
 func (i repoItem) FilterValue() string { return i.Repository.Title + i.Repository.Description }
 func (i repoItem) StoragePath() string {
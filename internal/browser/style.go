@@ -14,6 +14,10 @@ var spinnerStyle = lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light:
 var brightStyle = lipgloss.NewStyle().
 	Foreground(lipgloss.AdaptiveColor{Light: "#1a1a1a", Dark: "#dddddd"})
 
+var accessStyle = lipgloss.NewStyle().Faint(true)
+
+var newStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.AdaptiveColor{Light: "#04B575", Dark: "#04B575"})
+
 var dimmedColor = lipgloss.AdaptiveColor{Light: "#A49FA5", Dark: "#7F7C82"}
 var selectedColor = lipgloss.AdaptiveColor{Light: "#A49FA5", Dark: "#777777"}
 var titleBarStyle = list.DefaultStyles().TitleBar.Background(lipgloss.Color("#52006A")).Padding(0, 1)
@@ -28,6 +28,13 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	newListModel, cmd := m.list.Update(msg)
 	m.list = newListModel
 	m.selectedItemStoragePath = selected.repoStoragePath
+
+	// the item selected at startup is only viewed once the user interacts
+	// with the list
+	current, _ := m.list.SelectedItem().(repoItem)
+	if _, isKey := msg.(tea.KeyMsg); isKey || current.Repository != selected.Repository {
+		cmds = append(cmds, m.markViewed())
+	}
 	return m, tea.Batch(append(cmds, cmd)...)
 }
 
@@ -61,7 +68,7 @@ func handleMsg(msg tea.Msg, m *model, selected repoItem) tea.Cmd {
 
 			msg.repo.SetTitle(brightStyle.Render(msg.repo.Repository.Title))
 
-			m.list.SetItem(msg.index, msg.repo)
+			m.setItem(msg.repo)
 			return updateBottomStatusBarMsg(statusMessageStyle("[Cloned] " + repoString))
 		})
 
@@ -142,7 +149,7 @@ func handleKeyMsg(msg tea.Msg, m *model, selected repoItem) tea.Cmd {
 			cmds = append(cmds, tea.Batch(
 				m.list.StartSpinner(),
 				func() tea.Msg {
-					return cloneRepoMsg{selected, m.list.Index()}
+					return cloneRepoMsg{selected}
 				},
 			))
 		case "w":
@@ -153,6 +160,8 @@ func handleKeyMsg(msg tea.Msg, m *model, selected repoItem) tea.Cmd {
 			cmds = append(cmds, func() tea.Msg {
 				return openURLMsg(selected.Repository.BrowserPullRequestsURL)
 			})
+		case "s":
+			cmds = append(cmds, m.toggleStarred())
 		default:
 			log.Println("Key Pressed", string(msg.Runes))
 		}
@@ -0,0 +1,217 @@
+package credentials
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/wmalik/ogit/internal/gitconfig"
+)
+
+// gitCredentialTimeout is how long git credential helpers may take to answer
+const gitCredentialTimeout = 10 * time.Second
+
+// defaultHosts are the hosts of the providers whose accounts do not need a
+// baseURL
+var defaultHosts = map[string]string{
+	"github":    "github.com",
+	"gitlab":    "gitlab.com",
+	"bitbucket": "bitbucket.org",
+	"sourcehut": "git.sr.ht",
+}
+
+// Token is the API token of an account along with where it was found
+type Token struct {
+	Value string
+	// e.g. "environment variable GITHUB_TOKEN", empty if no token was found
+	Source string
+}
+
+// Resolve returns the API token of account, looked up in order in
+//
+//   - the token setting of the account section
+//   - the environment variable of the account e.g. GITHUB_TOKEN
+//   - the git credential helpers, for the host of the account
+//   - the config of the gh (GitHub) or glab (GitLab) CLI, for the host of the
+//     account
+//
+// An empty Token is returned if none of them has one.
+func Resolve(ctx context.Context, account gitconfig.Account) Token {
+	if token := account.Token; token != "" {
+		return Token{Value: token, Source: fmt.Sprintf("config (ogit.%s.token)", account.Name)}
+	}
+
+	if token := os.Getenv(account.TokenEnv); token != "" {
+		return Token{Value: token, Source: "environment variable " + account.TokenEnv}
+	}
+
+	host := Host(account)
+	if host == "" {
+		return Token{}
+	}
+
+	if token := gitCredential(ctx, host); token != "" {
+		return Token{Value: token, Source: "git credential (" + host + ")"}
+	}
+
+	switch account.Kind {
+	case "github":
+		if token, path := ghToken(host); token != "" {
+			return Token{Value: token, Source: "gh config (" + path + ")"}
+		}
+	case "gitlab":
+		if token, path := glabToken(host); token != "" {
+			return Token{Value: token, Source: "glab config (" + path + ")"}
+		}
+	}
+
+	return Token{}
+}
+
+// Host returns the hostname of the provider instance of account e.g.
+// github.com or gitlab.example.com, or an empty string if it is unknown
+func Host(account gitconfig.Account) string {
+	if account.BaseURL == "" {
+		return defaultHosts[account.Kind]
+	}
+
+	baseURL := account.BaseURL
+	if !strings.Contains(baseURL, "://") {
+		baseURL = "https://" + baseURL
+	}
+
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// gitCredential returns the password stored for host by the git credential
+// helpers. git is not allowed to prompt for it.
+func gitCredential(ctx context.Context, host string) string {
+	ctx, cancel := context.WithTimeout(ctx, gitCredentialTimeout)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	// git falls back from GIT_ASKPASS to core.askPass and SSH_ASKPASS, so
+	// all of them are disabled
+	cmd := exec.CommandContext(ctx, "git",
+		"-c", "core.askPass=",
+		"-c", "credential.interactive=false",
+		"credential", "fill",
+	)
+	cmd.Stdin = strings.NewReader("protocol=https\nhost=" + host + "\n\n")
+	cmd.Stdout = &stdout
+	cmd.Stderr = ioutil.Discard
+	cmd.Env = append(withoutEnv(os.Environ(), "GIT_ASKPASS", "SSH_ASKPASS"), "GIT_TERMINAL_PROMPT=0", "GCM_INTERACTIVE=never")
+	if err := cmd.Run(); err != nil {
+		return ""
+	}
+
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		if password := strings.TrimPrefix(scanner.Text(), "password="); password != scanner.Text() {
+			return password
+		}
+	}
+
+	return ""
+}
+
+// withoutEnv returns env without the variables named names
+func withoutEnv(env []string, names ...string) []string {
+	res := []string{}
+	for _, v := range env {
+		keep := true
+		for _, name := range names {
+			if strings.HasPrefix(v, name+"=") {
+				keep = false
+			}
+		}
+		if keep {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// ghToken returns the token stored for host in the config of the gh CLI,
+// along with the path of the config
+func ghToken(host string) (string, string) {
+	dir := configDir("GH_CONFIG_DIR", "gh")
+	if dir == "" {
+		return "", ""
+	}
+	path := filepath.Join(dir, "hosts.yml")
+
+	var hosts map[string]struct {
+		OAuthToken string `yaml:"oauth_token"`
+	}
+	if !readYAML(path, &hosts) {
+		return "", ""
+	}
+
+	return hosts[host].OAuthToken, path
+}
+
+// glabToken returns the token stored for host in the config of the glab CLI,
+// along with the path of the config
+func glabToken(host string) (string, string) {
+	dir := configDir("GLAB_CONFIG_DIR", "glab-cli")
+	if dir == "" {
+		return "", ""
+	}
+	path := filepath.Join(dir, "config.yml")
+
+	var config struct {
+		Hosts map[string]struct {
+			Token string `yaml:"token"`
+		} `yaml:"hosts"`
+	}
+	if !readYAML(path, &config) {
+		return "", ""
+	}
+
+	return config.Hosts[host].Token, path
+}
+
+// configDir returns the config directory of a CLI, which is read from the
+// environment variable env or else is name in the XDG config directory. An
+// empty string is returned if the home directory is unknown.
+func configDir(env string, name string) string {
+	if dir := os.Getenv(env); dir != "" {
+		return dir
+	}
+
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, name)
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", name)
+}
+
+// readYAML decodes the YAML file at path into out, and returns whether it
+// could be read
+func readYAML(path string, out interface{}) bool {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	return yaml.Unmarshal(raw, out) == nil
+}
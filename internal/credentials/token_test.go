@@ -0,0 +1,56 @@
+package credentials_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/wmalik/ogit/internal/credentials"
+	"github.com/wmalik/ogit/internal/gitconfig"
+)
+
+var _ = Describe("Token from git credential helpers", func() {
+	var dir string
+	var env map[string]string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "ogit-credentials")
+		Expect(err).To(BeNil())
+
+		// an askpass helper which records that it was asked for a password
+		askpass := filepath.Join(dir, "askpass")
+		Expect(os.WriteFile(askpass, []byte("#!/bin/sh\ntouch "+filepath.Join(dir, "prompted")+"\necho secret\n"), 0o755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, ".gitconfig"), []byte("[core]\n\taskPass = "+askpass+"\n"), 0o644)).To(Succeed())
+
+		env = map[string]string{}
+		for name, value := range map[string]string{
+			"HOME":                dir,
+			"XDG_CONFIG_HOME":     dir,
+			"GIT_CONFIG_NOSYSTEM": "1",
+			"GIT_ASKPASS":         askpass,
+			"SSH_ASKPASS":         askpass,
+		} {
+			env[name] = os.Getenv(name)
+			Expect(os.Setenv(name, value)).To(Succeed())
+		}
+	})
+	AfterEach(func() {
+		for name, value := range env {
+			os.Setenv(name, value)
+		}
+		os.RemoveAll(dir)
+	})
+	It("Does not prompt for a password via askpass helpers", func() {
+		token := credentials.Resolve(context.Background(), gitconfig.Account{
+			Name:    "gitea",
+			Kind:    "gitea",
+			BaseURL: "https://git.example.com",
+		})
+		Expect(token.Value).To(BeEmpty())
+		Expect(filepath.Join(dir, "prompted")).NotTo(BeAnExistingFile())
+	})
+})
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 	"gorm.io/driver/sqlite"
@@ -23,7 +24,7 @@ func NewDB(dbPath string) (*Database, error) {
 }
 
 func (d *Database) Init() error {
-	if err := d.DB.AutoMigrate(&Repository{}); err != nil {
+	if err := d.DB.AutoMigrate(&Repository{}, &RateLimit{}, &SyncState{}, &SyncRun{}, &SyncChange{}); err != nil {
 		return err
 	}
 
@@ -39,10 +40,17 @@ func (d *Database) InsertRepository(ctx context.Context, repo *Repository) error
 	return nil
 }
 
+// UpsertRepositories inserts repos, updating the repositories which are already
+// present so that their URLs and metadata are up to date. Whether repositories
+// are new is left unchanged, see MarkNewRepositories.
 func (d *Database) UpsertRepositories(ctx context.Context, repos []Repository) error {
 	result := d.DB.
 		WithContext(ctx).
-		Clauses(clause.OnConflict{DoNothing: true}).
+		Omit("new").
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "provider"}, {Name: "owner"}, {Name: "name"}},
+			UpdateAll: true,
+		}).
 		CreateInBatches(&repos, 100)
 	if result.Error != nil {
 		return result.Error
@@ -51,6 +59,133 @@ func (d *Database) UpsertRepositories(ctx context.Context, repos []Repository) e
 	return nil
 }
 
+// RenameRepositories moves the stored repositories whose provider ID matches
+// one of repos under another owner or name to the owner and name of the
+// matching repository, and returns the renames
+func (d *Database) RenameRepositories(ctx context.Context, repos []Repository) ([]Rename, error) {
+	renames := []Rename{}
+	err := d.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var stored []Repository
+		if result := tx.Where("provider_id <> ''").Find(&stored); result.Error != nil {
+			return result.Error
+		}
+
+		storedByID := map[string][]Repository{}
+		storedByPath := map[string]bool{}
+		for _, repo := range stored {
+			storedByID[repo.Provider+"/"+repo.ProviderID] = append(storedByID[repo.Provider+"/"+repo.ProviderID], repo)
+			storedByPath[repo.Provider+"/"+repo.Owner+"/"+repo.Name] = true
+		}
+
+		for _, repo := range repos {
+			if repo.ProviderID == "" {
+				continue
+			}
+
+			for _, old := range storedByID[repo.Provider+"/"+repo.ProviderID] {
+				if old.Owner == repo.Owner && old.Name == repo.Name {
+					continue
+				}
+
+				if storedByPath[repo.Provider+"/"+repo.Owner+"/"+repo.Name] {
+					// the repository at the new path is updated by
+					// UpsertRepositories
+					if result := tx.Unscoped().Delete(&old); result.Error != nil {
+						return result.Error
+					}
+				} else {
+					if result := tx.Model(&old).Updates(map[string]interface{}{
+						"owner": repo.Owner,
+						"name":  repo.Name,
+						"title": repo.Title,
+					}); result.Error != nil {
+						return result.Error
+					}
+					storedByPath[repo.Provider+"/"+repo.Owner+"/"+repo.Name] = true
+				}
+
+				renames = append(renames, Rename{
+					Provider: repo.Provider,
+					OldOwner: old.Owner,
+					OldName:  old.Name,
+					Owner:    repo.Owner,
+					Name:     repo.Name,
+				})
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return renames, nil
+}
+
+// MarkGoneRepositories marks the stored repositories for which isGone returns
+// true as gone, and returns the newly marked ones. Repositories which are
+// fetched again are unmarked by UpsertRepositories.
+func (d *Database) MarkGoneRepositories(ctx context.Context, isGone func(Repository) bool) ([]Repository, error) {
+	var stored []Repository
+	if result := d.DB.WithContext(ctx).
+		Select("id", "provider", "owner", "name").
+		Where("gone = ?", false).
+		Find(&stored); result.Error != nil {
+		return nil, result.Error
+	}
+
+	gone := []Repository{}
+	ids := []uint{}
+	for _, repo := range stored {
+		if isGone(repo) {
+			gone = append(gone, repo)
+			ids = append(ids, repo.ID)
+		}
+	}
+
+	for start := 0; start < len(ids); start += 500 {
+		end := start + 500
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		if result := d.DB.WithContext(ctx).
+			Model(&Repository{}).
+			Where("id IN ?", ids[start:end]).
+			Update("gone", true); result.Error != nil {
+			return nil, result.Error
+		}
+	}
+
+	return gone, nil
+}
+
+// MarkNewRepositories marks the stored repositories matching repos as new
+func (d *Database) MarkNewRepositories(ctx context.Context, repos []Repository) error {
+	return d.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, repo := range repos {
+			if result := tx.Model(&Repository{}).
+				Where("provider = ? AND owner = ? AND name = ?", repo.Provider, repo.Owner, repo.Name).
+				Update("new", true); result.Error != nil {
+				return result.Error
+			}
+		}
+
+		return nil
+	})
+}
+
+// MarkRepositoryViewed unmarks the repository with the given ID as new
+func (d *Database) MarkRepositoryViewed(ctx context.Context, id uint) error {
+	result := d.DB.WithContext(ctx).Model(&Repository{}).Where("id = ?", id).Update("new", false)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
 func (d *Database) SelectAllRepositories(ctx context.Context) ([]Repository, error) {
 	var repos []Repository
 	if result := d.DB.WithContext(ctx).Find(&repos); result.Error != nil {
@@ -75,11 +210,117 @@ func (d *Database) FindRepository(ctx context.Context, provider, org, name strin
 func (d *Database) SelectRepositories(ctx context.Context, org, filter string) ([]Repository, error) {
 	var repos []Repository
 	if result := d.DB.WithContext(ctx).
-		Where("owner = ?", org).
-		Where("name LIKE ?", "%"+filter+"%").
+		Where(`owner = ? OR owner LIKE ? ESCAPE '\'`, org, escapeLike(org)+"/%").
+		Where(`name LIKE ? ESCAPE '\'`, "%"+escapeLike(filter)+"%").
 		Find(&repos); result.Error != nil {
 		return nil, result.Error
 	}
 
 	return repos, nil
 }
+
+// likeEscaper escapes the wildcards of LIKE patterns, see escapeLike
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// escapeLike escapes s to match itself in a LIKE pattern with ESCAPE '\'
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// UpsertRateLimits stores the quotas of the providers, replacing the previously
+// stored ones
+func (d *Database) UpsertRateLimits(ctx context.Context, limits []RateLimit) error {
+	if len(limits) == 0 {
+		return nil
+	}
+
+	result := d.DB.
+		WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "provider"}},
+			UpdateAll: true,
+		}).
+		Create(&limits)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (d *Database) SelectRateLimits(ctx context.Context) ([]RateLimit, error) {
+	var limits []RateLimit
+	if result := d.DB.WithContext(ctx).Order("provider").Find(&limits); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return limits, nil
+}
+
+// UpsertSyncStates stores the states of the last fetch, replacing the
+// previously stored ones
+func (d *Database) UpsertSyncStates(ctx context.Context, states []SyncState) error {
+	if len(states) == 0 {
+		return nil
+	}
+
+	result := d.DB.
+		WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "provider"}, {Name: "owner"}},
+			UpdateAll: true,
+		}).
+		CreateInBatches(&states, 100)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (d *Database) SelectSyncStates(ctx context.Context, provider string) ([]SyncState, error) {
+	var states []SyncState
+	if result := d.DB.WithContext(ctx).
+		Where("provider = ?", provider).
+		Find(&states); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return states, nil
+}
+
+// InsertSyncRun stores run along with its changes
+func (d *Database) InsertSyncRun(ctx context.Context, run *SyncRun) error {
+	return d.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if result := tx.Omit("Changes").Create(run); result.Error != nil {
+			return result.Error
+		}
+
+		if len(run.Changes) == 0 {
+			return nil
+		}
+		for i := range run.Changes {
+			run.Changes[i].SyncRunID = run.ID
+		}
+		if result := tx.CreateInBatches(&run.Changes, 100); result.Error != nil {
+			return result.Error
+		}
+
+		return nil
+	})
+}
+
+// SelectSyncRuns returns the last limit runs along with their changes, most
+// recent first
+func (d *Database) SelectSyncRuns(ctx context.Context, limit int) ([]SyncRun, error) {
+	var runs []SyncRun
+	if result := d.DB.WithContext(ctx).
+		Preload("Changes").
+		Order("synced_at DESC").
+		Limit(limit).
+		Find(&runs); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return runs, nil
+}
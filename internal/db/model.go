@@ -1,13 +1,17 @@
 package db
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Repository struct {
 	gorm.Model
-	Provider               string
-	Title                  string `gorm:"unique"`
-	Owner                  string
-	Name                   string
+	Provider               string `gorm:"uniqueIndex:idx_repositories_provider_owner_name"`
+	Title                  string
+	Owner                  string `gorm:"uniqueIndex:idx_repositories_provider_owner_name"`
+	Name                   string `gorm:"uniqueIndex:idx_repositories_provider_owner_name"`
 	Description            string
 	BrowserHomepageURL     string
 	BrowserPullRequestsURL string
@@ -18,6 +22,33 @@ type Repository struct {
 	SettingsURL            string
 	HTTPSCloneURL          string
 	SSHCloneURL            string
+	Access                 string
+	Starred                bool
+	// whether the repository was missing upstream at the last complete fetch
+	Gone bool
+	// whether the repository was added by a sync and not viewed in the TUI
+	// since
+	New bool
+	Metadata
+}
+
+// Metadata are the attributes of a repository reported by some providers
+type Metadata struct {
+	// the ID of the repository on the provider, used to detect renames
+	ProviderID    string `gorm:"index"`
+	Stars         int
+	Language      string
+	DefaultBranch string
+	Archived      bool
+	Fork          bool
+	Parent        string
+	Visibility    string
+	// comma separated list of topics
+	Topics string
+	// the size of the repository in KB
+	Size          int
+	PushedAt      time.Time
+	LastUpdatedAt time.Time
 }
 
 func NewRepository(
@@ -34,7 +65,10 @@ func NewRepository(
 	releasesURL,
 	settingsURL,
 	httpsCloneURL,
-	sshCloneURL string,
+	sshCloneURL,
+	access string,
+	starred bool,
+	metadata Metadata,
 ) Repository {
 	return Repository{
 		Provider:               provider,
@@ -51,5 +85,79 @@ func NewRepository(
 		SettingsURL:            settingsURL,
 		HTTPSCloneURL:          httpsCloneURL,
 		SSHCloneURL:            sshCloneURL,
+		Access:                 access,
+		Starred:                starred,
+		Metadata:               metadata,
 	}
 }
+
+// Rename is a repository which was renamed or transferred upstream since it
+// was last fetched
+type Rename struct {
+	Provider string
+	OldOwner string
+	OldName  string
+	Owner    string
+	Name     string
+}
+
+// The kinds of changes of a repository made by a sync
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeRenamed = "renamed"
+	ChangeUpdated = "updated"
+)
+
+// SyncRun is a run of ogit fetch along with the changes it made
+type SyncRun struct {
+	gorm.Model
+	SyncedAt time.Time
+	// whether all repositories were fetched, rather than the ones updated
+	// since the previous run
+	Full bool
+	// the owners whose repositories could not be fetched, if any
+	Error   string
+	Changes []SyncChange
+}
+
+// SyncChange is a change of a repository made by a sync
+type SyncChange struct {
+	gorm.Model
+	SyncRunID uint `gorm:"index"`
+	Provider  string
+	Owner     string
+	Name      string
+	// e.g. ChangeAdded
+	Kind string
+	// the previous owner and name of renamed repositories
+	OldOwner string
+	OldName  string
+}
+
+// RateLimit is the API quota left on a provider after the last fetch
+type RateLimit struct {
+	gorm.Model
+	// the name of the provider e.g. github or gitlab.work
+	Provider  string `gorm:"uniqueIndex"`
+	Quota     int
+	Remaining int
+	ResetAt   time.Time
+}
+
+// SyncState is the state of the last fetch of the repositories of an owner on
+// a provider, from which the next fetch is resumed
+type SyncState struct {
+	gorm.Model
+	// the name of the provider e.g. github or gitlab.work
+	Provider string `gorm:"uniqueIndex:idx_sync_states_provider_owner"`
+	// the owner of the repositories, empty for the authenticated user
+	Owner      string `gorm:"uniqueIndex:idx_sync_states_provider_owner"`
+	LastSyncAt time.Time
+	// the time of the most recent update of the fetched repositories, in
+	// RFC 3339 format
+	Cursor string
+	// when all repositories of the owner were last fetched, rather than only
+	// the ones updated since the last fetch
+	FullSyncAt time.Time
+}
@@ -0,0 +1,40 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/wmalik/ogit/internal/credentials"
+	"github.com/wmalik/ogit/internal/gitconfig"
+)
+
+// HandleCommandDefault prints where the API token of each account is read
+// from, without printing the tokens
+func HandleCommandDefault(ctx context.Context) error {
+	gitConf, err := gitconfig.ReadGitConfig()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	fmt.Printf("Storage path: %s\n\n", gitConf.StoragePath())
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ACCOUNT\tHOST\tTOKEN SOURCE")
+	for _, account := range gitConf.Accounts() {
+		host := credentials.Host(account)
+		if host == "" {
+			host = "-"
+		}
+
+		source := credentials.Resolve(ctx, account).Source
+		if source == "" {
+			source = fmt.Sprintf("none (set %s or ogit.%s.token)", account.TokenEnv, account.Name)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", account.Name, host, source)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,169 @@
+package gitconfig
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// defaultAccounts are the accounts which are always present, even if they
+// are not configured explicitly
+var defaultAccounts = []string{"github", "gitlab"}
+
+// Account is the configuration of an account on a provider instance, read from
+// either an [ogit "<provider>"] section or from a named
+// [ogit "<provider>.<name>"] section
+type Account struct {
+	// the name of the config section e.g. gitlab or gitlab.work
+	Name string
+	// the kind of provider e.g. github, gitlab or gitea
+	Kind string
+	// the API URL of a self-hosted instance, empty for github.com/gitlab.com
+	BaseURL string
+	// the upload API URL of a GitHub Enterprise Server instance, defaults to
+	// BaseURL
+	UploadURL string
+	// the API token configured explicitly via token, which takes precedence
+	// over TokenEnv
+	Token string
+	// the environment variable containing the API token of the account e.g.
+	// GITLAB_TOKEN or GITLAB_WORK_TOKEN, unless overridden via tokenEnv
+	TokenEnv string
+	// organizations (GitHub/Gitea) or groups (GitLab) to fetch
+	Orgs []string
+	// whether to fetch all organizations/groups of the authenticated user in
+	// addition to Orgs (GitHub/GitLab)
+	AutoDiscoverOrgs bool
+	// organizations/groups which are never fetched
+	ExcludeOrgs []string
+	// whether to fetch the repositories starred (or watched, GitHub only) by
+	// the authenticated user
+	FetchStarred bool
+	FetchWatched bool
+	// all settings of the section keyed by lower case names e.g. baseurl
+	Settings map[string]string
+}
+
+// getAccounts reads all [ogit "<provider>"] and [ogit "<provider>.<name>"]
+// sections
+func getAccounts() ([]Account, error) {
+	sections, names, err := getSubsections("ogit")
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(defaultAccounts) - 1; i >= 0; i-- {
+		if _, ok := sections[defaultAccounts[i]]; !ok {
+			names = append([]string{defaultAccounts[i]}, names...)
+		}
+	}
+
+	accounts := []Account{}
+	for _, name := range names {
+		accounts = append(accounts, newAccount(name, sections[name]))
+	}
+
+	return accounts, nil
+}
+
+// newAccount returns the account configured in section. The keys of settings
+// are expected in lower case, as returned by git.
+func newAccount(section string, settings map[string]string) Account {
+	tokenEnv := settings["tokenenv"]
+	if tokenEnv == "" {
+		tokenEnv = defaultTokenEnv(section)
+	}
+
+	return Account{
+		Name:             section,
+		Kind:             strings.SplitN(section, ".", 2)[0],
+		BaseURL:          settings["baseurl"],
+		UploadURL:        settings["uploadurl"],
+		Token:            settings["token"],
+		TokenEnv:         tokenEnv,
+		Orgs:             splitList(settings["orgs"]),
+		AutoDiscoverOrgs: parseBool(settings["autodiscoverorgs"]),
+		ExcludeOrgs:      splitList(settings["excludeorgs"]),
+		FetchStarred:     parseBool(settings["fetchstarred"]),
+		FetchWatched:     parseBool(settings["fetchwatched"]),
+		Settings:         settings,
+	}
+}
+
+// defaultTokenEnv returns the name of the environment variable containing the
+// API token of the account configured in section, e.g. GITLAB_WORK_TOKEN for
+// gitlab.work
+func defaultTokenEnv(section string) string {
+	name := strings.NewReplacer(".", "_", "-", "_").Replace(section)
+	return strings.ToUpper(name) + "_TOKEN"
+}
+
+// parseBool returns whether value is one of the true values of git config
+func parseBool(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "yes", "on", "1":
+		return true
+	}
+	return false
+}
+
+// splitList splits a comma separated list of values
+func splitList(raw string) []string {
+	values := []string{}
+	for _, value := range strings.Split(raw, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+// getSubsections returns the settings of all [<section> "<subsection>"]
+// sections keyed by subsection name, along with the subsection names in the
+// order in which they appear in the config. Settings of the section itself
+// (e.g. ogit.storagePath) are ignored.
+func getSubsections(section string) (map[string]map[string]string, []string, error) {
+	var stdout bytes.Buffer
+	cmd := exec.Command("git", "config", "--null", "--get-regexp", "^"+regexp.QuoteMeta(section)+`\.`)
+	cmd.Stdout = &stdout
+	cmd.Stderr = ioutil.Discard
+
+	if err := cmd.Run(); err != nil {
+		var exitError *exec.ExitError
+		if errors.As(err, &exitError) && exitError.ExitCode() == 1 {
+			// no keys of the section are configured
+			return map[string]map[string]string{}, []string{}, nil
+		}
+		return nil, nil, err
+	}
+
+	prefix := section + "."
+	sections := map[string]map[string]string{}
+	names := []string{}
+	for _, entry := range strings.Split(stdout.String(), "\000") {
+		// entries look like <section>.<subsection>.<variable>\n<value>, and
+		// the subsection may itself contain dots
+		key, value := entry, ""
+		if newline := strings.Index(entry, "\n"); newline >= 0 {
+			key, value = entry[:newline], entry[newline+1:]
+		}
+
+		dot := strings.LastIndex(key, ".")
+		if dot < len(prefix) || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		subsection := key[len(prefix):dot]
+		if _, ok := sections[subsection]; !ok {
+			sections[subsection] = map[string]string{}
+			names = append(names, subsection)
+		}
+		sections[subsection][key[dot+1:]] = strings.TrimSpace(value)
+	}
+
+	return sections, names, nil
+}
@@ -0,0 +1,114 @@
+package gitconfig
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// allAccounts is the subsection of the filter applied to all accounts
+const allAccounts = "*"
+
+// Filter is the configuration of the repositories kept when fetching, read
+// from an [ogit-filter "<account>"], [ogit-filter "<account>/<org>"] or
+// [ogit-filter "*"] section. Settings which are not configured are nil.
+type Filter struct {
+	// the name of the account e.g. github.work, empty for all accounts
+	Account string
+	// the organization/group e.g. greatorg, empty for all of the account
+	Org string
+
+	ExcludeForks    *bool
+	ExcludeArchived *bool
+	Visibility      []string
+	IncludeNames    []string
+	ExcludeNames    []string
+	IncludeRegex    *regexp.Regexp
+	ExcludeRegex    *regexp.Regexp
+	IncludeTopics   []string
+	ExcludeTopics   []string
+}
+
+// getFilters reads all [ogit-filter "<subsection>"] sections
+func getFilters() ([]Filter, error) {
+	sections, names, err := getSubsections("ogit-filter")
+	if err != nil {
+		return nil, err
+	}
+
+	filters := []Filter{}
+	for _, name := range names {
+		filter, err := newFilter(name, sections[name])
+		if err != nil {
+			return nil, fmt.Errorf("ogit-filter %q: %w", name, err)
+		}
+		filters = append(filters, filter)
+	}
+
+	return filters, nil
+}
+
+// newFilter returns the filter configured in section. The keys of settings
+// are expected in lower case, as returned by git.
+func newFilter(section string, settings map[string]string) (Filter, error) {
+	filter := Filter{
+		ExcludeForks:    optionalBool(settings, "excludeforks"),
+		ExcludeArchived: optionalBool(settings, "excludearchived"),
+		Visibility:      optionalList(settings, "visibility"),
+		IncludeNames:    optionalList(settings, "includenames"),
+		ExcludeNames:    optionalList(settings, "excludenames"),
+		IncludeTopics:   optionalList(settings, "includetopics"),
+		ExcludeTopics:   optionalList(settings, "excludetopics"),
+	}
+
+	if section != allAccounts {
+		parts := strings.SplitN(section, "/", 2)
+		filter.Account = parts[0]
+		if len(parts) == 2 {
+			filter.Org = parts[1]
+		}
+	}
+
+	var err error
+	if filter.IncludeRegex, err = optionalRegexp(settings, "includeregex"); err != nil {
+		return Filter{}, err
+	}
+	if filter.ExcludeRegex, err = optionalRegexp(settings, "excluderegex"); err != nil {
+		return Filter{}, err
+	}
+
+	return filter, nil
+}
+
+// optionalBool returns the boolean value of key, or nil if it is not set
+func optionalBool(settings map[string]string, key string) *bool {
+	value, ok := settings[key]
+	if !ok {
+		return nil
+	}
+
+	b := parseBool(value)
+	return &b
+}
+
+// optionalList returns the comma separated values of key, or nil if it is not
+// set
+func optionalList(settings map[string]string, key string) []string {
+	value, ok := settings[key]
+	if !ok {
+		return nil
+	}
+
+	return splitList(value)
+}
+
+// optionalRegexp returns the regular expression of key, or nil if it is not
+// set
+func optionalRegexp(settings map[string]string, key string) (*regexp.Regexp, error) {
+	value, ok := settings[key]
+	if !ok || value == "" {
+		return nil, nil
+	}
+
+	return regexp.Compile(value)
+}
@@ -9,9 +9,9 @@ import (
 )
 
 type GitConfig struct {
-	orgs         []string
-	gitlabGroups []string
-	storagePath  string
+	accounts    []Account
+	filters     []Filter
+	storagePath string
 	// whether to fetch repos associated with the authenticated user
 	fetchUserRepos bool
 	useSSHAgent    bool
@@ -33,21 +33,17 @@ func ReadGitConfig() (*GitConfig, error) {
 
 	conf := defaultGitConfig()
 
-	orgs, err := getOrgs()
+	accounts, err := getAccounts()
 	if err != nil {
 		return nil, err
 	}
-	if len(orgs) > 0 {
-		conf.orgs = orgs
-	}
+	conf.accounts = accounts
 
-	gitlabGroups, err := getGitlabGroups()
+	filters, err := getFilters()
 	if err != nil {
 		return nil, err
 	}
-	if len(gitlabGroups) > 0 {
-		conf.gitlabGroups = gitlabGroups
-	}
+	conf.filters = filters
 
 	storagePath, err := getStoragePath()
 	if err != nil {
@@ -79,12 +75,16 @@ func ReadGitConfig() (*GitConfig, error) {
 	return conf, nil
 }
 
-func (c GitConfig) Orgs() []string {
-	return c.orgs
+// Accounts returns the configured provider accounts. The github and gitlab
+// accounts (i.e. on github.com and gitlab.com unless overridden via baseURL)
+// are always present.
+func (c GitConfig) Accounts() []Account {
+	return c.accounts
 }
 
-func (c GitConfig) GitlabGroups() []string {
-	return c.gitlabGroups
+// Filters returns the configured filters of the fetched repositories
+func (c GitConfig) Filters() []Filter {
+	return c.filters
 }
 
 func (c GitConfig) StoragePath() string {
@@ -103,44 +103,6 @@ func (c GitConfig) PrivKeyPath() string {
 	return c.privKeyPath
 }
 
-func getOrgs() ([]string, error) {
-	orgsRaw, err := gitconfig.Entire("ogit.github.orgs")
-	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			return []string{}, nil
-		}
-		return nil, err
-	}
-
-	orgs := []string{}
-	for _, org := range strings.Split(orgsRaw, ",") {
-		if org != "" {
-			orgs = append(orgs, strings.TrimSpace(org))
-		}
-	}
-
-	return orgs, err
-}
-
-func getGitlabGroups() ([]string, error) {
-	gitlabGroupsRaw, err := gitconfig.Entire("ogit.gitlab.orgs")
-	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			return []string{}, nil
-		}
-		return nil, err
-	}
-
-	gitlabGroups := []string{}
-	for _, org := range strings.Split(gitlabGroupsRaw, ",") {
-		if org != "" {
-			gitlabGroups = append(gitlabGroups, strings.TrimSpace(org))
-		}
-	}
-
-	return gitlabGroups, err
-}
-
 func getStoragePath() (string, error) {
 	var storagePath string
 	var err error
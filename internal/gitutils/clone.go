@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
 )
 
@@ -110,7 +111,7 @@ func (gu *GitUtils) CloneToDisk(ctx context.Context, httpsURL, sshURL, path stri
 			URL:      cloneURL,
 			Progress: progress,
 			Depth:    1,
-			Auth:     gu.auth,
+			Auth:     authForURL(gu.auth, cloneURL),
 		},
 	)
 	if err != nil {
@@ -147,6 +148,29 @@ func (gu *GitUtils) CloneToDisk(ctx context.Context, httpsURL, sshURL, path stri
 	return repository.String(), nil
 }
 
+// authForURL returns auth for the user of the clone URL, as servers other than
+// the hosted providers (e.g. alice@git.example.com:repo.git) do not
+// necessarily accept the git user
+func authForURL(auth ssh.AuthMethod, cloneURL string) ssh.AuthMethod {
+	endpoint, err := transport.NewEndpoint(cloneURL)
+	if err != nil || endpoint.User == "" {
+		return auth
+	}
+
+	switch a := auth.(type) {
+	case *ssh.PublicKeysCallback:
+		withUser := *a
+		withUser.User = endpoint.User
+		return &withUser
+	case *ssh.PublicKeys:
+		withUser := *a
+		withUser.User = endpoint.User
+		return &withUser
+	}
+
+	return auth
+}
+
 // Cloned checks if a path contains a .git directory
 func Cloned(dir string) (bool, error) {
 	if _, err := os.Stat(path.Join(dir, ".git")); err != nil {
@@ -0,0 +1,114 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+
+	"github.com/wmalik/ogit/internal/db"
+	"github.com/wmalik/ogit/internal/gitconfig"
+)
+
+// HandleCommandDefault prints the changes made by the last limit runs of ogit
+// fetch, most recent first
+func HandleCommandDefault(ctx context.Context, limit int) error {
+	gitConf, err := gitconfig.ReadGitConfig()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	localDB, err := db.NewDB(path.Join(gitConf.StoragePath(), "ogit.db"))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if err := localDB.Init(); err != nil {
+		log.Fatalln(err)
+	}
+
+	runs, err := localDB.SelectSyncRuns(ctx, limit)
+	if err != nil {
+		return err
+	}
+
+	if len(runs) == 0 {
+		fmt.Println("No fetch recorded yet, run ogit fetch")
+		return nil
+	}
+
+	for i, run := range runs {
+		if i > 0 {
+			fmt.Println()
+		}
+		PrintRun(os.Stdout, run)
+	}
+
+	return nil
+}
+
+// PrintRun prints the changes made by run, grouped by provider e.g.
+//
+//	2022-01-02 15:04 (incremental)
+//	  github: 1 added, 0 removed, 1 renamed, 0 updated
+//	    + github/wmalik/ogit
+//	    > github/wmalik/dotfiles (was wmalik/dots)
+func PrintRun(out io.Writer, run db.SyncRun) {
+	kind := "incremental"
+	if run.Full {
+		kind = "full"
+	}
+	fmt.Fprintf(out, "%s (%s)\n", run.SyncedAt.Local().Format("2006-01-02 15:04"), kind)
+
+	if len(run.Changes) == 0 {
+		fmt.Fprintln(out, "  no changes")
+	}
+
+	providers := []string{}
+	changes := map[string][]db.SyncChange{}
+	for _, change := range run.Changes {
+		if _, ok := changes[change.Provider]; !ok {
+			providers = append(providers, change.Provider)
+		}
+		changes[change.Provider] = append(changes[change.Provider], change)
+	}
+
+	for _, provider := range providers {
+		counts := map[string]int{}
+		for _, change := range changes[provider] {
+			counts[change.Kind]++
+		}
+		fmt.Fprintf(out, "  %s: %d added, %d removed, %d renamed, %d updated\n",
+			provider,
+			counts[db.ChangeAdded],
+			counts[db.ChangeRemoved],
+			counts[db.ChangeRenamed],
+			counts[db.ChangeUpdated],
+		)
+
+		for _, change := range changes[provider] {
+			fmt.Fprintf(out, "    %s\n", formatChange(change))
+		}
+	}
+
+	if run.Error != "" {
+		fmt.Fprintf(out, "  failed: %s\n", run.Error)
+	}
+}
+
+// formatChange returns change as a line of the history
+func formatChange(change db.SyncChange) string {
+	repo := path.Join(change.Provider, change.Owner, change.Name)
+	switch change.Kind {
+	case db.ChangeAdded:
+		return "+ " + repo
+	case db.ChangeRemoved:
+		return "- " + repo
+	case db.ChangeRenamed:
+		return fmt.Sprintf("> %s (was %s/%s)", repo, change.OldOwner, change.OldName)
+	default:
+		return "~ " + repo
+	}
+}
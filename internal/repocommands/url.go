@@ -6,9 +6,11 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/wmalik/ogit/internal/db"
 	"github.com/wmalik/ogit/internal/gitconfig"
+	"github.com/wmalik/ogit/internal/gitutils"
 	"github.com/wmalik/ogit/internal/utils"
 )
 
@@ -76,8 +78,12 @@ func findRepositoryCWD(ctx context.Context) (*db.Repository, error) {
 		return nil, err
 	}
 
-	repo, err := database.FindRepository(ctx,
-		providerFromPath(cwd), orgFromPath(cwd), nameFromPath(cwd))
+	provider, owner, name, err := repositoryFromPath(gitConf.StoragePath(), cwd)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := database.FindRepository(ctx, provider, owner, name)
 	if err != nil {
 		return nil, err
 	}
@@ -85,14 +91,55 @@ func findRepositoryCWD(ctx context.Context) (*db.Repository, error) {
 	return repo, nil
 }
 
-func providerFromPath(path string) string {
-	return filepath.Base(filepath.Dir(filepath.Dir(path)))
-}
+// repositoryFromPath returns the provider, owner and name of the cloned
+// repository containing dir. Repositories are stored at
+// <storagePath>/<provider>/<owner>/<name>, where the owner may consist of
+// several directories e.g. group/subgroup of GitLab.
+func repositoryFromPath(storagePath, dir string) (provider, owner, name string, err error) {
+	root, err := repositoryRoot(dir)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	storagePath, err = filepath.EvalSymlinks(storagePath)
+	if err != nil {
+		return "", "", "", err
+	}
 
-func orgFromPath(path string) string {
-	return filepath.Base(filepath.Dir(path))
+	rel, err := filepath.Rel(storagePath, root)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) < 3 || parts[0] == ".." {
+		return "", "", "", fmt.Errorf("%s is not a repository in %s", root, storagePath)
+	}
+
+	return parts[0], strings.Join(parts[1:len(parts)-1], "/"), parts[len(parts)-1], nil
 }
 
-func nameFromPath(path string) string {
-	return filepath.Base(path)
+// repositoryRoot returns the closest directory containing dir which contains
+// a cloned repository
+func repositoryRoot(dir string) (string, error) {
+	dir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		cloned, err := gitutils.Cloned(dir)
+		if err != nil {
+			return "", err
+		}
+		if cloned {
+			return dir, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("not inside a cloned repository")
+		}
+		dir = parent
+	}
 }
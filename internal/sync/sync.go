@@ -2,51 +2,373 @@ package sync
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path"
+	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/wmalik/ogit/internal/credentials"
 	"github.com/wmalik/ogit/internal/db"
 	"github.com/wmalik/ogit/internal/gitconfig"
 	"github.com/wmalik/ogit/service"
 	"github.com/wmalik/ogit/upstream"
 )
 
+// fullSyncInterval is how often all repositories of an owner are fetched,
+// rather than only the ones updated since the last sync, so that the ones
+// deleted upstream are noticed
+const fullSyncInterval = 7 * 24 * time.Hour
+
+// Result is the outcome of a sync
+type Result struct {
+	// the repositories renamed or transferred upstream since the last sync
+	Renames []db.Rename
+	// the run as recorded in the sync history
+	Run db.SyncRun
+}
+
 // Sync fetches the repository metadata from upstream and stores it in the local
-// database (on disk)
-func Sync(ctx context.Context, gitConf *gitconfig.GitConfig) error {
-	gitlabClient, err := upstream.NewGitlabClientWithToken(os.Getenv("GITLAB_TOKEN"))
+// database (on disk). Unless full is set, only the repositories updated since
+// the last sync are fetched from the providers which support it, besides a
+// full fetch of each owner every fullSyncInterval. Stored repositories are
+// renamed when their provider ID is fetched under another path, and marked as
+// gone when they are missing from a full fetch of their owner.
+func Sync(ctx context.Context, gitConf *gitconfig.GitConfig, full bool) (*Result, error) {
+	registry := service.NewRegistry()
+	for _, account := range gitConf.Accounts() {
+		token := credentials.Resolve(ctx, account)
+		if token.Source != "" {
+			log.Printf("[%s] using the API token from %s", account.Name, token.Source)
+		}
+
+		client, err := upstream.NewClient(account.Kind, upstream.ClientOptions{
+			Name:             account.Name,
+			BaseURL:          account.BaseURL,
+			UploadURL:        account.UploadURL,
+			Token:            token.Value,
+			AutoDiscoverOrgs: account.AutoDiscoverOrgs,
+			ExcludeOrgs:      account.ExcludeOrgs,
+			FetchStarred:     account.FetchStarred,
+			FetchWatched:     account.FetchWatched,
+			CacheDir:         path.Join(gitConf.StoragePath(), ".cache", "http"),
+			UseSSHAgent:      gitConf.UseSSHAgent(),
+			PrivKeyPath:      gitConf.PrivKeyPath(),
+			Settings:         account.Settings,
+		})
+		if err != nil {
+			log.Printf("[%s] %s", account.Name, err)
+			client = upstream.NewUnavailableClient(err)
+		}
+
+		if err := registry.Register(account.Name, client, account.Orgs); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	localDB, err := db.NewDB(path.Join(gitConf.StoragePath(), "ogit.db"))
 	if err != nil {
 		log.Fatalln(err)
 	}
 
-	rs := service.NewRepositoryService(
-		upstream.NewGithubClientWithToken(os.Getenv("GITHUB_TOKEN")),
-		gitlabClient,
-		gitConf.FetchUserRepos(),
-	)
+	if err := localDB.Init(); err != nil {
+		log.Fatalln(err)
+	}
+
+	incremental := map[string]map[string]db.SyncState{}
+	if !full {
+		incremental, err = setUpdatedSince(ctx, localDB, registry)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	rs := service.NewRepositoryService(registry, gitConf.FetchUserRepos()).
+		WithFilters(toServiceFilters(gitConf.Filters()))
 
 	log.Println("Syncing repositories")
-	repos, err := rs.GetRepositoriesByOwners(ctx, gitConf.Orgs(), gitConf.GitlabGroups())
+	syncedAt := time.Now()
+	repos, fetchErr := rs.GetRepositories(ctx)
+	rateLimits := getRateLimits(registry)
+	var partial *service.FetchError
+	if fetchErr != nil && !errors.As(fetchErr, &partial) {
+		log.Fatalln(fetchErr)
+	}
+	printFetchResults(os.Stdout, rs.Results())
+
+	stored, err := localDB.SelectAllRepositories(ctx)
 	if err != nil {
 		log.Fatalln(err)
 	}
 
-	localDB, err := db.NewDB(path.Join(gitConf.StoragePath(), "ogit.db"))
+	dbRepos := toDatabaseRepositories(repos)
+	result := &Result{}
+	result.Renames, err = localDB.RenameRepositories(ctx, dbRepos)
 	if err != nil {
 		log.Fatalln(err)
 	}
+	for _, rename := range result.Renames {
+		log.Printf("[%s] %s/%s was renamed to %s/%s", rename.Provider, rename.OldOwner, rename.OldName, rename.Owner, rename.Name)
+	}
 
-	if err := localDB.Init(); err != nil {
+	changes, added := diffRepositories(stored, dbRepos, result.Renames)
+	if err := localDB.UpsertRepositories(ctx, dbRepos); err != nil {
 		log.Fatalln(err)
 	}
 
-	if err := localDB.UpsertRepositories(ctx, toDatabaseRepositories(repos)); err != nil {
+	// all repositories are added by the first sync, so none of them is new
+	if len(stored) > 0 {
+		if err := localDB.MarkNewRepositories(ctx, added); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	gone, err := localDB.MarkGoneRepositories(ctx, func(repo db.Repository) bool {
+		return rs.IsGone(repo.Provider, repo.Owner, repo.Name)
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(gone) > 0 {
+		log.Printf("%d repositories are gone upstream", len(gone))
+	}
+	for _, repo := range gone {
+		changes = append(changes, db.SyncChange{
+			Provider: repo.Provider,
+			Owner:    repo.Owner,
+			Name:     repo.Name,
+			Kind:     db.ChangeRemoved,
+		})
+	}
+
+	result.Run = db.SyncRun{SyncedAt: syncedAt, Full: len(incremental) == 0, Changes: changes}
+	if fetchErr != nil {
+		result.Run.Error = fetchErr.Error()
+	}
+	if err := localDB.InsertSyncRun(ctx, &result.Run); err != nil {
 		log.Fatalln(err)
 	}
 
-	return nil
+	if err := localDB.UpsertRateLimits(ctx, rateLimits); err != nil {
+		log.Fatalln(err)
+	}
+
+	if err := localDB.UpsertSyncStates(ctx, getSyncStates(registry, syncedAt, incremental)); err != nil {
+		log.Fatalln(err)
+	}
+
+	return result, fetchErr
+}
+
+// diffRepositories returns the changes from the stored repositories to the
+// fetched ones, besides the removed ones, and the added repositories.
+// Repositories which were gone upstream and are fetched again are added.
+func diffRepositories(stored []db.Repository, fetched []db.Repository, renames []db.Rename) ([]db.SyncChange, []db.Repository) {
+	storedByPath := map[string]db.Repository{}
+	for _, repo := range stored {
+		storedByPath[repo.Provider+"/"+repo.Owner+"/"+repo.Name] = repo
+	}
+
+	changes := []db.SyncChange{}
+	renamed := map[string]bool{}
+	for _, rename := range renames {
+		renamed[rename.Provider+"/"+rename.Owner+"/"+rename.Name] = true
+		changes = append(changes, db.SyncChange{
+			Provider: rename.Provider,
+			Owner:    rename.Owner,
+			Name:     rename.Name,
+			Kind:     db.ChangeRenamed,
+			OldOwner: rename.OldOwner,
+			OldName:  rename.OldName,
+		})
+	}
+
+	added := []db.Repository{}
+	for _, repo := range fetched {
+		key := repo.Provider + "/" + repo.Owner + "/" + repo.Name
+		if renamed[key] {
+			continue
+		}
+
+		change := db.SyncChange{Provider: repo.Provider, Owner: repo.Owner, Name: repo.Name}
+		old, ok := storedByPath[key]
+		switch {
+		case !ok || old.Gone:
+			change.Kind = db.ChangeAdded
+			added = append(added, repo)
+		case updated(old, repo):
+			change.Kind = db.ChangeUpdated
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, added
+}
+
+// updated returns whether repo was pushed to, or its description or settings
+// changed, since old was stored. Changes of e.g. the stars are ignored.
+func updated(old db.Repository, repo db.Repository) bool {
+	return old.Description != repo.Description ||
+		old.DefaultBranch != repo.DefaultBranch ||
+		old.Archived != repo.Archived ||
+		old.Visibility != repo.Visibility ||
+		old.Topics != repo.Topics ||
+		repo.PushedAt.After(old.PushedAt)
+}
+
+// printFetchResults prints a table of the number of repositories fetched for
+// each provider and owner, and of the errors
+func printFetchResults(out io.Writer, results []service.FetchResult) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROVIDER\tOWNER\tFETCHED\tERROR")
+	for _, result := range results {
+		owner := result.Owner
+		if owner == "" {
+			owner = "*"
+		}
+		if result.Starred {
+			owner += " (starred)"
+		}
+
+		errMsg := "-"
+		if result.Err != nil {
+			errMsg = result.Err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", result.Provider, owner, result.Fetched, errMsg)
+	}
+	w.Flush()
+}
+
+// getRateLimits logs and returns the API quota left on the providers which
+// report it
+func getRateLimits(registry *service.Registry) []db.RateLimit {
+	limits := []db.RateLimit{}
+	for _, provider := range registry.Providers() {
+		reporter, ok := provider.Client.(upstream.RateLimitReporter)
+		if !ok {
+			continue
+		}
+
+		limit, ok := reporter.GetRateLimit()
+		if !ok {
+			continue
+		}
+
+		log.Printf("[%s] remaining API calls: %d/%d, resets at %s",
+			provider.Name,
+			limit.Remaining,
+			limit.Limit,
+			limit.Reset.Format(time.Kitchen),
+		)
+		limits = append(limits, db.RateLimit{
+			Provider:  provider.Name,
+			Quota:     limit.Limit,
+			Remaining: limit.Remaining,
+			ResetAt:   limit.Reset,
+		})
+	}
+
+	return limits
+}
+
+// setUpdatedSince makes the providers which support it fetch only the
+// repositories updated since the last sync, unless all repositories of the
+// owner were last fetched more than fullSyncInterval ago. The stored states
+// of the owners fetched incrementally are returned by provider and owner.
+func setUpdatedSince(ctx context.Context, localDB *db.Database, registry *service.Registry) (map[string]map[string]db.SyncState, error) {
+	incremental := map[string]map[string]db.SyncState{}
+	for _, provider := range registry.Providers() {
+		client, ok := provider.Client.(upstream.IncrementalClient)
+		if !ok {
+			continue
+		}
+
+		states, err := localDB.SelectSyncStates(ctx, provider.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		since := map[string]time.Time{}
+		for _, state := range states {
+			if time.Since(state.FullSyncAt) > fullSyncInterval {
+				continue
+			}
+
+			cursor, err := time.Parse(time.RFC3339, state.Cursor)
+			if err != nil {
+				log.Printf("[%s] ignoring invalid sync cursor %q of %q: %s", provider.Name, state.Cursor, state.Owner, err)
+				continue
+			}
+			since[state.Owner] = cursor
+			if incremental[provider.Name] == nil {
+				incremental[provider.Name] = map[string]db.SyncState{}
+			}
+			incremental[provider.Name][state.Owner] = state
+		}
+		client.SetUpdatedSince(since)
+	}
+
+	return incremental, nil
+}
+
+// getSyncStates returns the state of the sync for each owner of the providers
+// which support incremental syncs, given the stored states of the owners
+// fetched incrementally
+func getSyncStates(registry *service.Registry, syncedAt time.Time, incremental map[string]map[string]db.SyncState) []db.SyncState {
+	states := []db.SyncState{}
+	for _, provider := range registry.Providers() {
+		client, ok := provider.Client.(upstream.IncrementalClient)
+		if !ok {
+			continue
+		}
+
+		for owner, until := range client.UpdatedUntil() {
+			fullSyncAt := syncedAt
+			if state, ok := incremental[provider.Name][owner]; ok {
+				fullSyncAt = state.FullSyncAt
+			}
+
+			states = append(states, db.SyncState{
+				Provider:   provider.Name,
+				Owner:      owner,
+				LastSyncAt: syncedAt,
+				Cursor:     until.UTC().Format(time.RFC3339),
+				FullSyncAt: fullSyncAt,
+			})
+		}
+	}
+
+	return states
+}
+
+func toServiceFilters(filters []gitconfig.Filter) service.Filters {
+	res := service.Filters{}
+	for _, filter := range filters {
+		key := filter.Account
+		if filter.Org != "" {
+			key = filter.Account + "/" + filter.Org
+		}
+
+		res[key] = service.Filter{
+			ExcludeForks:    filter.ExcludeForks,
+			ExcludeArchived: filter.ExcludeArchived,
+			Visibility:      filter.Visibility,
+			IncludeNames:    filter.IncludeNames,
+			ExcludeNames:    filter.ExcludeNames,
+			IncludeRegex:    filter.IncludeRegex,
+			ExcludeRegex:    filter.ExcludeRegex,
+			IncludeTopics:   filter.IncludeTopics,
+			ExcludeTopics:   filter.ExcludeTopics,
+		}
+	}
+
+	return res
 }
 
 func toDatabaseRepositories(repos *service.Repositories) []db.Repository {
@@ -67,6 +389,22 @@ func toDatabaseRepositories(repos *service.Repositories) []db.Repository {
 			repo.SettingsURL,
 			repo.HTTPSCloneURL,
 			repo.SSHCloneURL,
+			repo.Access,
+			repo.Starred,
+			db.Metadata{
+				ProviderID:    repo.ID,
+				Stars:         repo.Stars,
+				Language:      repo.Language,
+				DefaultBranch: repo.DefaultBranch,
+				Archived:      repo.Archived,
+				Fork:          repo.Fork,
+				Parent:        repo.Parent,
+				Visibility:    repo.Visibility,
+				Topics:        strings.Join(repo.Topics, ","),
+				Size:          repo.Size,
+				PushedAt:      repo.PushedAt,
+				LastUpdatedAt: repo.UpdatedAt,
+			},
 		),
 		)
 	}
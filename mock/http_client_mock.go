@@ -49,7 +49,10 @@ func (hc *HTTPClient) Client() *http.Client {
 func (rt roundTripperMocked) RoundTrip(r *http.Request) (*http.Response, error) {
 	w := httptest.NewRecorder()
 	rt.mux.ServeHTTP(w, r)
-	return w.Result(), nil
+	resp := w.Result()
+	// as with real transports, the response refers to its request
+	resp.Request = r
+	return resp, nil
 }
 
 // Mock registers an HTTP handler for the provided method and path
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/wmalik/ogit/upstream"
+)
+
+// Filter decides which of the fetched repositories are kept. Unset fields
+// (i.e. nil) do not filter, and are inherited from less specific filters.
+type Filter struct {
+	ExcludeForks    *bool
+	ExcludeArchived *bool
+	// the visibilities of the kept repositories e.g. public or internal
+	Visibility []string
+	// glob patterns of the names of the kept and dropped repositories
+	IncludeNames []string
+	ExcludeNames []string
+	// regular expressions of the names of the kept and dropped repositories
+	IncludeRegex *regexp.Regexp
+	ExcludeRegex *regexp.Regexp
+	// repositories are kept if they have any of IncludeTopics and none of
+	// ExcludeTopics
+	IncludeTopics []string
+	ExcludeTopics []string
+}
+
+// Filters are the filters of the registered providers. A filter is keyed by
+// the name of the provider (e.g. github.work), by the name of the provider and
+// an owner (e.g. github.work/greatorg), or by the empty string for the filter
+// of all providers.
+type Filters map[string]Filter
+
+// forRepository returns the filter of a repository fetched by provider, i.e.
+// the filter of all providers overridden by the filter of the provider and by
+// the filters of the owner and of its parents (e.g. group and group/subgroup)
+func (f Filters) forRepository(provider, owner string) Filter {
+	filter := f.get("").merge(f.get(provider))
+
+	parts := strings.Split(owner, "/")
+	for i := 1; i <= len(parts); i++ {
+		filter = filter.merge(f.get(provider + "/" + strings.Join(parts[:i], "/")))
+	}
+
+	return filter
+}
+
+// get returns the filter of key, which is compared case-insensitively as
+// owners are case-insensitive on GitHub and GitLab
+func (f Filters) get(key string) Filter {
+	if filter, ok := f[key]; ok {
+		return filter
+	}
+
+	for k, filter := range f {
+		if strings.EqualFold(k, key) {
+			return filter
+		}
+	}
+
+	return Filter{}
+}
+
+// merge returns f with the fields set in override replaced
+func (f Filter) merge(override Filter) Filter {
+	if override.ExcludeForks != nil {
+		f.ExcludeForks = override.ExcludeForks
+	}
+	if override.ExcludeArchived != nil {
+		f.ExcludeArchived = override.ExcludeArchived
+	}
+	if override.Visibility != nil {
+		f.Visibility = override.Visibility
+	}
+	if override.IncludeNames != nil {
+		f.IncludeNames = override.IncludeNames
+	}
+	if override.ExcludeNames != nil {
+		f.ExcludeNames = override.ExcludeNames
+	}
+	if override.IncludeRegex != nil {
+		f.IncludeRegex = override.IncludeRegex
+	}
+	if override.ExcludeRegex != nil {
+		f.ExcludeRegex = override.ExcludeRegex
+	}
+	if override.IncludeTopics != nil {
+		f.IncludeTopics = override.IncludeTopics
+	}
+	if override.ExcludeTopics != nil {
+		f.ExcludeTopics = override.ExcludeTopics
+	}
+
+	return f
+}
+
+// Match returns whether repo is kept. The forks, archived and visibility
+// filters only apply to repositories whose provider reports their metadata.
+func (f Filter) Match(repo upstream.HostRepository) bool {
+	var metadata upstream.Metadata
+	if reporter, ok := repo.(upstream.MetadataReporter); ok {
+		metadata = reporter.GetMetadata()
+	}
+
+	if f.ExcludeForks != nil && *f.ExcludeForks && metadata.Fork {
+		return false
+	}
+	if f.ExcludeArchived != nil && *f.ExcludeArchived && metadata.Archived {
+		return false
+	}
+	if len(f.Visibility) > 0 && metadata.Visibility != "" && !containsFold(f.Visibility, metadata.Visibility) {
+		return false
+	}
+
+	name := repo.GetName()
+	if len(f.IncludeNames) > 0 && !matchAny(f.IncludeNames, name) {
+		return false
+	}
+	if matchAny(f.ExcludeNames, name) {
+		return false
+	}
+	if f.IncludeRegex != nil && !f.IncludeRegex.MatchString(name) {
+		return false
+	}
+	if f.ExcludeRegex != nil && f.ExcludeRegex.MatchString(name) {
+		return false
+	}
+
+	if len(f.IncludeTopics) > 0 && !containsAnyFold(f.IncludeTopics, metadata.Topics) {
+		return false
+	}
+	if containsAnyFold(f.ExcludeTopics, metadata.Topics) {
+		return false
+	}
+
+	return true
+}
+
+// matchAny returns whether name matches any of the glob patterns
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containsFold returns whether values contains value, ignoring case
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containsAnyFold returns whether values contains any of wanted, ignoring case
+func containsAnyFold(values, wanted []string) bool {
+	for _, value := range wanted {
+		if containsFold(values, value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,59 @@
+package service_test
+
+import (
+	"context"
+	"regexp"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/wmalik/ogit/service"
+	"github.com/wmalik/ogit/upstream"
+)
+
+var _ = Describe("Repository service with filters", func() {
+	var repositories *service.Repositories
+	BeforeEach(func() {
+		client := upstream.NewMockClient().WithRepositories([]upstream.MockRepository{
+			{Provider: "github", Owner: "greatorg", Name: "api"},
+			{Provider: "github", Owner: "greatorg", Name: "api-fork", Metadata: upstream.Metadata{Fork: true}},
+			{Provider: "github", Owner: "greatorg", Name: "legacy", Metadata: upstream.Metadata{Archived: true}},
+			{Provider: "github", Owner: "greatorg", Name: "secrets", Metadata: upstream.Metadata{Visibility: "private"}},
+			{Provider: "github", Owner: "greatorg", Name: "sandbox-1"},
+			{Provider: "github", Owner: "greatorg", Name: "tmp-notes"},
+			{Provider: "github", Owner: "greatorg", Name: "slides", Metadata: upstream.Metadata{Topics: []string{"talk"}}},
+			{Provider: "github", Owner: "forkorg", Name: "linux", Metadata: upstream.Metadata{Fork: true, Archived: true}},
+		})
+		registry := service.NewRegistry()
+		Expect(registry.Register("github", client, []string{"greatorg", "forkorg"})).To(Succeed())
+
+		yes, no := true, false
+		var err error
+		repositories, err = service.NewRepositoryService(registry, false).
+			WithFilters(service.Filters{
+				"": {
+					ExcludeArchived: &yes,
+				},
+				"github": {
+					ExcludeForks:  &yes,
+					Visibility:    []string{"public", "internal"},
+					ExcludeNames:  []string{"sandbox-*"},
+					ExcludeRegex:  regexp.MustCompile(`^tmp-`),
+					ExcludeTopics: []string{"Talk"},
+				},
+				"github/ForkOrg": {
+					ExcludeForks:    &no,
+					ExcludeArchived: &no,
+				},
+			}).
+			GetRepositories(context.Background())
+		Expect(err).To(BeNil())
+	})
+	It("Keeps the repositories matching the filters of their provider and owner", func() {
+		names := []string{}
+		for _, repo := range *repositories {
+			names = append(names, repo.Owner+"/"+repo.Name)
+		}
+		Expect(names).To(Equal([]string{"greatorg/api", "forkorg/linux"}))
+	})
+})
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/wmalik/ogit/upstream"
+)
+
+// Provider is a client of an upstream repository host along with the owners
+// (e.g. organizations, groups or users) whose repositories are fetched from it
+type Provider struct {
+	// a unique name of the provider e.g. github or gitlab.work
+	Name   string
+	Client upstream.RepositoryHostClient
+	Owners []string
+}
+
+// Registry contains the providers from which repositories are fetched
+type Registry struct {
+	providers []Provider
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a provider to the registry. Each provider must be registered
+// with a unique name.
+func (r *Registry) Register(name string, client upstream.RepositoryHostClient, owners []string) error {
+	for _, provider := range r.providers {
+		if provider.Name == name {
+			return fmt.Errorf("provider %q is already registered", name)
+		}
+	}
+
+	r.providers = append(r.providers, Provider{Name: name, Client: client, Owners: owners})
+	return nil
+}
+
+// Providers returns the registered providers in the order of registration
+func (r *Registry) Providers() []Provider {
+	return r.providers
+}
@@ -0,0 +1,54 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/wmalik/ogit/service"
+	"github.com/wmalik/ogit/upstream"
+)
+
+var _ = Describe("Provider registry", func() {
+	var registry *service.Registry
+	BeforeEach(func() {
+		registry = service.NewRegistry()
+		Expect(registry.Register("github", upstream.NewMockClient(), []string{"wmalik"})).To(Succeed())
+		Expect(registry.Register("gitlab", upstream.NewMockClient(), []string{"fdroid"})).To(Succeed())
+	})
+	It("Returns the providers in the order of registration", func() {
+		providers := registry.Providers()
+		Expect(len(providers)).To(Equal(2))
+		Expect(providers[0].Name).To(Equal("github"))
+		Expect(providers[0].Owners).To(Equal([]string{"wmalik"}))
+		Expect(providers[1].Name).To(Equal("gitlab"))
+		Expect(providers[1].Owners).To(Equal([]string{"fdroid"}))
+	})
+	It("Rejects providers registered twice with the same name", func() {
+		Expect(registry.Register("github", upstream.NewMockClient(), []string{})).NotTo(Succeed())
+		Expect(len(registry.Providers())).To(Equal(2))
+	})
+	It("Reports the name of the provider which failed", func() {
+		Expect(registry.Register("gitea", upstream.NewMockClient().WithError(errors.New("unauthorized")), []string{})).To(Succeed())
+		_, err := service.NewRepositoryService(registry, false).GetRepositories(context.Background())
+		Expect(err).To(MatchError("gitea: unauthorized"))
+	})
+	It("Returns the access of the user if reported by the provider", func() {
+		client, err := upstream.NewGitoliteClient("git@git.example.com",
+			func(ctx context.Context, command string) ([]byte, error) {
+				return []byte(" R W\tinfra/terraform\n R  \tinfra/scripts\n"), nil
+			},
+		)
+		Expect(err).To(BeNil())
+		registry = service.NewRegistry()
+		Expect(registry.Register("gitolite", client, []string{"infra"})).To(Succeed())
+
+		repos, err := service.NewRepositoryService(registry, false).GetRepositories(context.Background())
+		Expect(err).To(BeNil())
+		Expect(len(*repos)).To(Equal(2))
+		Expect((*repos)[0].Access).To(Equal(upstream.AccessWrite))
+		Expect((*repos)[1].Access).To(Equal(upstream.AccessRead))
+	})
+})
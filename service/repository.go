@@ -2,6 +2,10 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 
 	"github.com/wmalik/ogit/upstream"
 )
@@ -20,32 +24,133 @@ type Repository struct {
 	CIURL                  string
 	ReleasesURL            string
 	SettingsURL            string
+	// the access of the authenticated user e.g. upstream.AccessWrite, if
+	// reported by the provider
+	Access string
+	// whether the repository was fetched only because the user starred or
+	// watches it
+	Starred bool
+	// the stars, language, etc. of the repository, if reported by the
+	// provider
+	upstream.Metadata
 }
 
 type Repositories []Repository
 
 type RepositoryService struct {
-	client         upstream.RepositoryHostClient
-	gitlabClient   upstream.RepositoryHostClient
+	registry       *Registry
 	fetchUserRepos bool
+	filters        Filters
+	results        []FetchResult
+	// the repositories fetched by the last GetRepositories, including the
+	// ones dropped by a filter, keyed by provider/owner/name
+	fetched map[string]bool
+	// the owners whose repositories were all fetched by the last
+	// GetRepositories, by provider e.g. github.example.com
+	complete map[string][]string
 }
 
-func NewRepositoryService(client upstream.RepositoryHostClient, gitlabClient upstream.RepositoryHostClient, fetchUserRepos bool) *RepositoryService {
-	return &RepositoryService{client, gitlabClient, fetchUserRepos}
+func NewRepositoryService(registry *Registry, fetchUserRepos bool) *RepositoryService {
+	return &RepositoryService{registry: registry, fetchUserRepos: fetchUserRepos}
 }
 
-func (r *RepositoryService) GetRepositoriesByOwners(ctx context.Context, owners []string, gitlabOwners []string) (*Repositories, error) {
-	repositories, err := r.client.GetRepositories(ctx, owners, r.fetchUserRepos)
-	if err != nil {
-		return nil, err
+// WithFilters makes the service drop the fetched repositories which do not
+// match the filter of their provider and owner
+func (r *RepositoryService) WithFilters(filters Filters) *RepositoryService {
+	r.filters = filters
+	return r
+}
+
+// FetchResult is the outcome of fetching the repositories of an owner from a
+// provider
+type FetchResult struct {
+	// the name of the provider e.g. github or gitlab.work
+	Provider string
+	// the result for an owner, or for all owners of the provider if Owner is
+	// empty i.e. if the provider failed altogether or does not report the
+	// results of each owner
+	upstream.FetchResult
+}
+
+// FetchError is returned by GetRepositories, along with the repositories which
+// were fetched, when fetching the repositories of some owners failed
+type FetchError struct {
+	Failed []FetchResult
+}
+
+func (e *FetchError) Error() string {
+	msgs := make([]string, len(e.Failed))
+	for i, result := range e.Failed {
+		name := result.Provider
+		if result.Owner != "" {
+			name += "/" + result.Owner
+		}
+		if result.Starred {
+			name += " (starred)"
+		}
+		msgs[i] = fmt.Sprintf("%s: %s", name, result.Err)
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// GetRepositories fetches the repositories of all registered providers
+// concurrently. The repositories are returned in the order in which the
+// providers were registered, and repositories fetched by several providers
+// (e.g. multiple accounts having access to the same organization) are only
+// returned once. Failing providers or owners do not stop the others from being
+// fetched, in which case a FetchError is returned along with the repositories.
+func (r *RepositoryService) GetRepositories(ctx context.Context) (*Repositories, error) {
+	providers := r.registry.Providers()
+	results := make([][]upstream.HostRepository, len(providers))
+	fetchResults := make([][]FetchResult, len(providers))
+
+	var wg sync.WaitGroup
+	for i, provider := range providers {
+		wg.Add(1)
+		go func(i int, provider Provider) {
+			defer wg.Done()
+			repositories, err := provider.Client.GetRepositories(ctx, provider.Owners, r.fetchUserRepos)
+			results[i] = repositories
+			fetchResults[i] = toFetchResults(provider, repositories, err)
+		}(i, provider)
+	}
+	wg.Wait()
+
+	r.results = []FetchResult{}
+	failed := []FetchResult{}
+	for _, providerResults := range fetchResults {
+		r.results = append(r.results, providerResults...)
+		for _, result := range providerResults {
+			if result.Err != nil {
+				failed = append(failed, result)
+			}
+		}
 	}
 
-	gitlabRepositories, err := r.gitlabClient.GetRepositories(ctx, gitlabOwners, r.fetchUserRepos)
-	if err != nil {
-		return nil, err
+	r.fetched = map[string]bool{}
+	r.complete = map[string][]string{}
+	fetched := upstream.HostRepositories{}
+	for i, repositories := range results {
+		hosts := map[string]bool{}
+		for _, repo := range repositories {
+			hosts[repo.GetProvider()] = true
+			r.fetched[repo.GetProvider()+"/"+repo.GetOwner()+"/"+repo.GetName()] = true
+			if r.filters.forRepository(providers[i].Name, repo.GetOwner()).Match(repo) {
+				fetched = append(fetched, repo)
+			}
+		}
+
+		// the repositories of an owner are stored under the providers of
+		// the repositories fetched by the same client
+		for _, owner := range completeOwners(providers[i], fetchResults[i]) {
+			for host := range hosts {
+				r.complete[host] = append(r.complete[host], owner)
+			}
+		}
 	}
 
-	allRepositories := append(repositories, gitlabRepositories...)
+	allRepositories := fetched.DeDuplicate()
 
 	res := make(Repositories, len(allRepositories))
 	for i, repo := range allRepositories {
@@ -62,7 +167,85 @@ func (r *RepositoryService) GetRepositoriesByOwners(ctx context.Context, owners
 		res[i].SettingsURL = repo.GetSettingsURL()
 		res[i].HTTPSCloneURL = repo.GetHTTPSCloneURL()
 		res[i].SSHCloneURL = repo.GetSSHCloneURL()
+		if reporter, ok := repo.(upstream.AccessReporter); ok {
+			res[i].Access = reporter.GetAccess()
+		}
+		if reporter, ok := repo.(upstream.StarredReporter); ok {
+			res[i].Starred = reporter.IsStarred()
+		}
+		if reporter, ok := repo.(upstream.MetadataReporter); ok {
+			res[i].Metadata = reporter.GetMetadata()
+		}
+	}
 
+	if len(failed) > 0 {
+		return &res, &FetchError{Failed: failed}
 	}
 	return &res, nil
 }
+
+// Results returns the outcome of the last GetRepositories for each provider,
+// and for each owner of the providers which report it
+func (r *RepositoryService) Results() []FetchResult {
+	return r.results
+}
+
+// IsGone returns whether a repository is missing from the last
+// GetRepositories although all repositories of its provider and owner were
+// fetched. Repositories dropped by a filter, and the ones of owners which were
+// not fetched, failed or were only fetched incrementally, are not gone.
+func (r *RepositoryService) IsGone(provider, owner, name string) bool {
+	if r.fetched[provider+"/"+owner+"/"+name] {
+		return false
+	}
+
+	for _, completeOwner := range r.complete[provider] {
+		// the repositories of a group include the ones of its subgroups
+		if owner == completeOwner || (completeOwner != "" && strings.HasPrefix(owner, completeOwner+"/")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// completeOwners returns the owners whose repositories were all fetched from
+// provider. Clients which do not report the results of each owner may have
+// fetched any repositories visible to them, so only the configured owners are
+// complete when they succeed.
+func completeOwners(provider Provider, results []FetchResult) []string {
+	if _, ok := provider.Client.(upstream.FetchReporter); !ok {
+		if len(results) == 1 && results[0].Complete {
+			return provider.Owners
+		}
+		return nil
+	}
+
+	owners := []string{}
+	for _, result := range results {
+		if result.Complete && !result.Starred {
+			owners = append(owners, result.Owner)
+		}
+	}
+	return owners
+}
+
+// toFetchResults returns the results of fetching repositories from provider.
+// Errors other than upstream.PartialError mean that the provider failed
+// altogether.
+func toFetchResults(provider Provider, repositories []upstream.HostRepository, err error) []FetchResult {
+	results := []FetchResult{}
+	var partial *upstream.PartialError
+	reporter, ok := provider.Client.(upstream.FetchReporter)
+	if ok && (err == nil || errors.As(err, &partial)) {
+		for _, result := range reporter.FetchResults() {
+			results = append(results, FetchResult{Provider: provider.Name, FetchResult: result})
+		}
+		return results
+	}
+
+	return append(results, FetchResult{
+		Provider:    provider.Name,
+		FetchResult: upstream.FetchResult{Fetched: len(repositories), Complete: err == nil, Err: err},
+	})
+}
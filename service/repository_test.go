@@ -2,6 +2,7 @@ package service_test
 
 import (
 	"context"
+	"errors"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -17,8 +18,11 @@ var _ = Describe("Repository service", func() {
 		var err error
 		BeforeEach(func() {
 			gitlabClient := upstream.NewMockClient()
-			repoService = service.NewRepositoryService(upstream.NewMockClient(), gitlabClient, false)
-			repositories, err = repoService.GetRepositoriesByOwners(context.Background(), []string{}, []string{})
+			registry := service.NewRegistry()
+			Expect(registry.Register("github", upstream.NewMockClient(), []string{})).To(Succeed())
+			Expect(registry.Register("gitlab", gitlabClient, []string{})).To(Succeed())
+			repoService = service.NewRepositoryService(registry, false)
+			repositories, err = repoService.GetRepositories(context.Background())
 			Expect(err).To(BeNil())
 		})
 		It("Returns no repository", func() {
@@ -124,12 +128,33 @@ var _ = Describe("Repository service", func() {
 					SettingsURL:            "https://github.com/padawin/dotfiles/settings",
 				},
 			})
-			repoService = service.NewRepositoryService(client, gitlabClient, false)
-			repositories, err = repoService.GetRepositoriesByOwners(context.Background(), []string{"wmalik"}, []string{"wmalik"})
+			giteaClient := upstream.NewMockClient().WithRepositories([]upstream.MockRepository{
+				{
+					Provider:               "codeberg.org",
+					Owner:                  "wmalik",
+					Name:                   "ogit",
+					Description:            "TUI for browsing GitHub and GitLab orgnizations",
+					BrowserHomepageURL:     "https://codeberg.org/wmalik/ogit",
+					BrowserPullRequestsURL: "https://codeberg.org/wmalik/ogit/pulls",
+					HTTPSCloneURL:          "https://codeberg.org/wmalik/ogit.git",
+					SSHCloneURL:            "git@codeberg.org:wmalik/ogit.git",
+					OrgURL:                 "https://codeberg.org/wmalik",
+					IssuesURL:              "https://codeberg.org/wmalik/ogit/issues",
+					CIURL:                  "https://codeberg.org/wmalik/ogit/actions",
+					ReleasesURL:            "https://codeberg.org/wmalik/ogit/releases",
+					SettingsURL:            "https://codeberg.org/wmalik/ogit/settings",
+				},
+			})
+			registry := service.NewRegistry()
+			Expect(registry.Register("github", client, []string{"wmalik"})).To(Succeed())
+			Expect(registry.Register("gitlab", gitlabClient, []string{"wmalik"})).To(Succeed())
+			Expect(registry.Register("gitea", giteaClient, []string{"wmalik"})).To(Succeed())
+			repoService = service.NewRepositoryService(registry, false)
+			repositories, err = repoService.GetRepositories(context.Background())
 			Expect(err).To(BeNil())
 		})
 		It("Returns the matching repositories", func() {
-			Expect(len(*repositories)).To(Equal(4))
+			Expect(len(*repositories)).To(Equal(5))
 			Expect((*repositories)[0].Provider).To(Equal("github"))
 			Expect((*repositories)[0].Name).To(Equal("ogit"))
 			Expect((*repositories)[0].Description).To(Equal("TUI for browsing GitHub and GitLab orgnizations"))
@@ -158,6 +183,113 @@ var _ = Describe("Repository service", func() {
 			Expect((*repositories)[3].BrowserPullRequestsURL).To(Equal("https://gitlab.com/wmalik/dotfiles/pulls"))
 			Expect((*repositories)[3].HTTPSCloneURL).To(Equal("https://gitlab.com/wmalik/dotfiles.git"))
 			Expect((*repositories)[3].SSHCloneURL).To(Equal("git@gitlab.com/wmalik/dotfiles.git"))
+			Expect((*repositories)[4].Provider).To(Equal("codeberg.org"))
+			Expect((*repositories)[4].Name).To(Equal("ogit"))
+			Expect((*repositories)[4].BrowserHomepageURL).To(Equal("https://codeberg.org/wmalik/ogit"))
+			Expect((*repositories)[4].SSHCloneURL).To(Equal("git@codeberg.org:wmalik/ogit.git"))
+		})
+	})
+	Context("When multiple accounts of the same provider are provided", func() {
+		var repoService *service.RepositoryService
+		var repositories *service.Repositories
+		var err error
+		BeforeEach(func() {
+			personal := upstream.NewMockClient().WithRepositories([]upstream.MockRepository{
+				{Provider: "github", Owner: "wmalik", Name: "ogit"},
+				{Provider: "github", Owner: "charmbracelet", Name: "bubbletea"},
+			})
+			work := upstream.NewMockClient().WithRepositories([]upstream.MockRepository{
+				{Provider: "github", Owner: "acme", Name: "infra"},
+				{Provider: "github", Owner: "charmbracelet", Name: "bubbletea"},
+			})
+			registry := service.NewRegistry()
+			Expect(registry.Register("github", personal, []string{"wmalik", "charmbracelet"})).To(Succeed())
+			Expect(registry.Register("github.work", work, []string{"acme", "charmbracelet"})).To(Succeed())
+			repoService = service.NewRepositoryService(registry, false)
+			repositories, err = repoService.GetRepositories(context.Background())
+			Expect(err).To(BeNil())
+		})
+		It("Returns the repositories of all accounts without duplicates", func() {
+			Expect(len(*repositories)).To(Equal(3))
+			Expect((*repositories)[0].Owner).To(Equal("wmalik"))
+			Expect((*repositories)[1].Owner).To(Equal("charmbracelet"))
+			Expect((*repositories)[2].Owner).To(Equal("acme"))
+		})
+	})
+	Context("When a provider fails", func() {
+		var repoService *service.RepositoryService
+		var repositories *service.Repositories
+		var err error
+		BeforeEach(func() {
+			github := upstream.NewMockClient().WithRepositories([]upstream.MockRepository{
+				{Provider: "github", Owner: "wmalik", Name: "ogit"},
+			})
+			registry := service.NewRegistry()
+			Expect(registry.Register("gitea", upstream.NewMockClient().WithError(errors.New("unauthorized")), []string{"forgejo"})).To(Succeed())
+			Expect(registry.Register("github", github, []string{"wmalik"})).To(Succeed())
+			repoService = service.NewRepositoryService(registry, false)
+			repositories, err = repoService.GetRepositories(context.Background())
+		})
+		It("Returns the repositories of the other providers along with the failure", func() {
+			var fetchErr *service.FetchError
+			Expect(errors.As(err, &fetchErr)).To(BeTrue())
+			Expect(fetchErr.Failed).To(HaveLen(1))
+			Expect(fetchErr.Failed[0].Provider).To(Equal("gitea"))
+			Expect(len(*repositories)).To(Equal(1))
+			Expect((*repositories)[0].Name).To(Equal("ogit"))
+		})
+		It("Reports the number of repositories fetched from each provider", func() {
+			results := repoService.Results()
+			Expect(results).To(HaveLen(2))
+			Expect(results[0].Provider).To(Equal("gitea"))
+			Expect(results[0].Err).To(MatchError("unauthorized"))
+			Expect(results[1].Provider).To(Equal("github"))
+			Expect(results[1].Fetched).To(Equal(1))
+			Expect(results[1].Err).To(BeNil())
+		})
+	})
+	Context("When repositories are missing upstream", func() {
+		var repoService *service.RepositoryService
+		BeforeEach(func() {
+			github := upstream.NewMockClient().WithRepositories([]upstream.MockRepository{
+				{Provider: "github", Owner: "wmalik", Name: "ogit"},
+				{Provider: "github", Owner: "wmalik", Name: "dotfiles"},
+			})
+			registry := service.NewRegistry()
+			Expect(registry.Register("gitea", upstream.NewMockClient().WithError(errors.New("unauthorized")), []string{"forgejo"})).To(Succeed())
+			Expect(registry.Register("github", github, []string{"wmalik"})).To(Succeed())
+			repoService = service.NewRepositoryService(registry, false).
+				WithFilters(service.Filters{"github": service.Filter{ExcludeNames: []string{"dotfiles"}}})
+			_, _ = repoService.GetRepositories(context.Background())
+		})
+		It("Reports the repositories missing from a complete fetch as gone", func() {
+			Expect(repoService.IsGone("github", "wmalik", "deleted")).To(BeTrue())
+			Expect(repoService.IsGone("github", "wmalik", "ogit")).To(BeFalse())
+		})
+		It("Does not report the repositories dropped by a filter as gone", func() {
+			Expect(repoService.IsGone("github", "wmalik", "dotfiles")).To(BeFalse())
+		})
+		It("Does not report the repositories of failed or unknown providers as gone", func() {
+			Expect(repoService.IsGone("gitea", "forgejo", "deleted")).To(BeFalse())
+			Expect(repoService.IsGone("gitlab", "wmalik", "deleted")).To(BeFalse())
+		})
+	})
+	Context("When an account of a provider fails", func() {
+		var repoService *service.RepositoryService
+		BeforeEach(func() {
+			git := upstream.NewMockClient().WithRepositories([]upstream.MockRepository{
+				{Provider: "local", Owner: "git", Name: "a"},
+			})
+			registry := service.NewRegistry()
+			Expect(registry.Register("local", git, []string{"git"})).To(Succeed())
+			Expect(registry.Register("local.nas", upstream.NewMockClient().WithError(errors.New("no such directory")), []string{"mirrors"})).To(Succeed())
+			repoService = service.NewRepositoryService(registry, false)
+			_, _ = repoService.GetRepositories(context.Background())
+		})
+		It("Only reports the repositories of the configured owners of the other accounts as gone", func() {
+			Expect(repoService.IsGone("local", "git", "deleted")).To(BeTrue())
+			Expect(repoService.IsGone("local", "mirrors", "b")).To(BeFalse())
+			Expect(repoService.IsGone("local", "unconfigured", "c")).To(BeFalse())
 		})
 	})
 })
@@ -0,0 +1,68 @@
+package upstream
+
+import "strings"
+
+// mergeOwners returns the configured owners followed by the discovered owners
+// which are not configured, leaving out the excluded owners. Owners are
+// compared case-insensitively, as on GitHub and GitLab.
+func mergeOwners(configured, discovered, excluded []string) []string {
+	seen := map[string]bool{}
+	for _, owner := range excluded {
+		seen[strings.ToLower(owner)] = true
+	}
+
+	all := append(append([]string{}, configured...), discovered...)
+
+	owners := []string{}
+	for _, owner := range all {
+		if seen[strings.ToLower(owner)] {
+			continue
+		}
+		seen[strings.ToLower(owner)] = true
+		owners = append(owners, owner)
+	}
+
+	return owners
+}
+
+// ownedBy returns whether owner is one of owners, or a subgroup of one of them.
+// Owners are compared case-insensitively.
+func ownedBy(owner string, owners []string) bool {
+	owner = strings.ToLower(owner)
+	for _, o := range owners {
+		o = strings.ToLower(o)
+		if owner == o || strings.HasPrefix(owner, o+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// topLevelPaths removes the paths nested in other paths e.g. group/subgroup if
+// group is present, as the projects of subgroups are fetched along with the
+// projects of their parent group
+func topLevelPaths(paths []string) []string {
+	present := map[string]bool{}
+	for _, p := range paths {
+		present[strings.ToLower(p)] = true
+	}
+
+	res := []string{}
+	for _, p := range paths {
+		nested := false
+		parts := strings.Split(strings.ToLower(p), "/")
+		for i := 1; i < len(parts); i++ {
+			if present[strings.Join(parts[:i], "/")] {
+				nested = true
+				break
+			}
+		}
+
+		if !nested {
+			res = append(res, p)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,127 @@
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ClientOptions configures the clients created via NewClient
+type ClientOptions struct {
+	// the name of the config section of the provider e.g. github.work
+	Name string
+	// the API URL of a self-hosted instance
+	BaseURL string
+	// the upload API URL of a self-hosted instance (GitHub Enterprise Server)
+	UploadURL string
+	// the API token used to authenticate with the instance
+	Token string
+	// whether to fetch the organizations/groups of the authenticated user in
+	// addition to the configured ones (GitHub/GitLab), except for ExcludeOrgs
+	AutoDiscoverOrgs bool
+	ExcludeOrgs      []string
+	// whether to fetch the repositories starred (or watched, GitHub only) by
+	// the authenticated user
+	FetchStarred bool
+	FetchWatched bool
+	// the directory in which API responses are cached (GitHub/GitLab), no
+	// responses are cached if empty
+	CacheDir string
+	// the SSH authentication configured via ogit.sshAuth
+	UseSSHAgent bool
+	PrivKeyPath string
+	// all settings of the config section of the provider, keyed by lower case
+	// names (e.g. baseurl)
+	Settings map[string]string
+}
+
+// ClientFactory creates a RepositoryHostClient for the provided options
+type ClientFactory func(opts ClientOptions) (RepositoryHostClient, error)
+
+// clientFactories contains a ClientFactory for each supported kind of provider
+var clientFactories = map[string]ClientFactory{
+	"github": func(opts ClientOptions) (RepositoryHostClient, error) {
+		client, err := NewGithubClientWithToken(opts.Token, opts.BaseURL, opts.UploadURL, opts.CacheDir)
+		if err != nil {
+			return nil, err
+		}
+		if opts.AutoDiscoverOrgs {
+			client.WithOrgDiscovery(opts.ExcludeOrgs)
+		}
+		if opts.FetchStarred {
+			client.WithStarred()
+		}
+		if opts.FetchWatched {
+			client.WithWatched()
+		}
+		if strings.EqualFold(opts.Settings["api"], "graphql") {
+			if opts.Token == "" {
+				return nil, fmt.Errorf("the graphql api requires a token")
+			}
+			client.WithGraphQL(client.httpClient)
+		}
+		return client, nil
+	},
+	"gitlab": func(opts ClientOptions) (RepositoryHostClient, error) {
+		client, err := NewGitlabClientWithToken(opts.Token, opts.BaseURL, opts.CacheDir)
+		if err != nil {
+			return nil, err
+		}
+		if opts.AutoDiscoverOrgs {
+			client.WithGroupDiscovery(opts.ExcludeOrgs)
+		}
+		if opts.FetchStarred {
+			client.WithStarred()
+		}
+		return client, nil
+	},
+	"gitea": func(opts ClientOptions) (RepositoryHostClient, error) {
+		return NewGiteaClientWithToken(opts.BaseURL, opts.Token)
+	},
+	"bitbucket": func(opts ClientOptions) (RepositoryHostClient, error) {
+		return NewBitbucketClientWithToken(opts.Token, opts.Settings["username"]), nil
+	},
+	"gerrit": func(opts ClientOptions) (RepositoryHostClient, error) {
+		return NewGerritClientWithToken(opts.BaseURL, opts.Settings["username"], opts.Token)
+	},
+	"sourcehut": func(opts ClientOptions) (RepositoryHostClient, error) {
+		return NewSourcehutClientWithToken(opts.Token), nil
+	},
+	"manifest": func(opts ClientOptions) (RepositoryHostClient, error) {
+		return NewManifestClientWithToken(opts.Settings["path"], opts.Token)
+	},
+	"local": func(opts ClientOptions) (RepositoryHostClient, error) {
+		return NewLocalClientWithPaths(opts.Settings["paths"])
+	},
+	"gitolite": func(opts ClientOptions) (RepositoryHostClient, error) {
+		return NewGitoliteClientWithSSHAuth(opts.BaseURL, opts.UseSSHAgent, opts.PrivKeyPath)
+	},
+}
+
+// NewClient returns a client for the provider of the given kind e.g. github.
+// Kinds which are not supported natively are delegated to a plugin executable
+// named ogit-provider-<kind>, if found in PATH.
+func NewClient(kind string, opts ClientOptions) (RepositoryHostClient, error) {
+	factory, ok := clientFactories[kind]
+	if !ok {
+		return lookupPlugin(kind, opts)
+	}
+
+	return factory(opts)
+}
+
+// UnavailableClient is a RepositoryHostClient which could not be created e.g.
+// because the provider is not supported. Fetching its repositories fails with
+// the error of creating it, so that it fails like any other provider rather
+// than stopping the others from being fetched.
+type UnavailableClient struct {
+	err error
+}
+
+func NewUnavailableClient(err error) *UnavailableClient {
+	return &UnavailableClient{err: err}
+}
+
+func (c *UnavailableClient) GetRepositories(ctx context.Context, owners []string, fetchUserRepos bool) ([]HostRepository, error) {
+	return nil, c.err
+}
@@ -0,0 +1,109 @@
+package upstream
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FetchResult is the outcome of fetching the repositories of an owner
+type FetchResult struct {
+	Owner string
+	// whether the result is for the repositories starred or watched by the
+	// authenticated user (Owner) rather than owned by it
+	Starred bool
+	// the number of repositories fetched
+	Fetched int
+	// whether all repositories of the owner were fetched, rather than only
+	// the ones updated since the last fetch or none because of Err
+	Complete bool
+	Err      error
+}
+
+// FetchReporter is implemented by RepositoryHostClient types which fetch the
+// repositories of each owner separately, and keep fetching the repositories of
+// the other owners when fetching the ones of an owner fails
+type FetchReporter interface {
+	// FetchResults returns the outcome of the last GetRepositories for each
+	// owner
+	FetchResults() []FetchResult
+}
+
+// PartialError is returned by GetRepositories, along with the repositories
+// which were fetched, when fetching the repositories of some owners failed
+type PartialError struct {
+	Failed []FetchResult
+}
+
+func (e *PartialError) Error() string {
+	msgs := make([]string, len(e.Failed))
+	for i, result := range e.Failed {
+		owner := result.Owner
+		if result.Starred {
+			owner += " (starred)"
+		}
+		msgs[i] = fmt.Sprintf("%s: %s", owner, result.Err)
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// fetchResults implements FetchReporter for the clients embedding it
+type fetchResults struct {
+	mu      sync.Mutex
+	results []FetchResult
+}
+
+func (r *fetchResults) FetchResults() []FetchResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	results := make([]FetchResult, len(r.results))
+	copy(results, r.results)
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Starred != results[j].Starred {
+			return !results[i].Starred
+		}
+		return results[i].Owner < results[j].Owner
+	})
+	return results
+}
+
+// reset forgets the results of the previous GetRepositories
+func (r *fetchResults) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = nil
+}
+
+// record stores the outcome of fetching repos of owner, which were updated
+// after since unless it is zero
+func (r *fetchResults) record(owner string, starred bool, since time.Time, repos []HostRepository, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, FetchResult{
+		Owner:    owner,
+		Starred:  starred,
+		Fetched:  len(repos),
+		Complete: since.IsZero() && err == nil,
+		Err:      err,
+	})
+}
+
+// err returns a PartialError listing the owners whose repositories could not
+// be fetched, or nil if there are none
+func (r *fetchResults) err() error {
+	failed := []FetchResult{}
+	for _, result := range r.FetchResults() {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+	return &PartialError{Failed: failed}
+}
@@ -0,0 +1,84 @@
+package upstream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// errNotFound is returned by getJSON if the requested resource does not exist
+var errNotFound = errors.New("not found")
+
+// getJSON performs a GET request, decodes the JSON response into v and returns
+// the response headers
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) (http.Header, error) {
+	body, header, err := get(ctx, client, url)
+	if err != nil {
+		return nil, err
+	}
+
+	return header, json.Unmarshal(body, v)
+}
+
+// get performs a GET request accepting JSON and returns the response body and
+// headers
+func get(ctx context.Context, client *http.Client, url string) ([]byte, http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return do(client, req)
+}
+
+// postJSON performs a POST request with the JSON encoding of body and decodes
+// the JSON response into v
+func postJSON(ctx context.Context, client *http.Client, url string, body interface{}, v interface{}) error {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(encoded))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, _, err := do(client, req)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(resp, v)
+}
+
+// do sends a request accepting JSON and returns the response body and headers
+func do(client *http.Client, req *http.Request) ([]byte, http.Header, error) {
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil, errNotFound
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("%s %s: unexpected status %s", req.Method, req.URL, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return body, resp.Header, nil
+}
@@ -0,0 +1,184 @@
+package upstream
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// cacheMaxAge is how long cached responses which are not requested again are
+// kept
+const cacheMaxAge = 30 * 24 * time.Hour
+
+// cacheIgnoredParams are the query parameters left out of the cache key, as
+// they change on every fetch e.g. the time of the last fetch. Cached responses
+// are still only used if the server confirms that they are up to date.
+var cacheIgnoredParams = []string{"last_activity_after"}
+
+// cachingTransport caches the responses of GET requests which have an ETag or
+// a Last-Modified header on disk, and revalidates them with conditional
+// requests. A 304 Not Modified response is replaced by the cached response,
+// which does not count against the rate limit of GitHub. Responses which were
+// not requested for cacheMaxAge are evicted.
+type cachingTransport struct {
+	dir   string
+	base  http.RoundTripper
+	prune sync.Once
+}
+
+// NewCachingTransport returns a transport caching responses in dir, which is
+// created if needed, and sending requests via base (http.DefaultTransport if
+// nil)
+func NewCachingTransport(dir string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &cachingTransport{dir: dir, base: base}
+}
+
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.base.RoundTrip(req)
+	}
+
+	t.prune.Do(t.evict)
+
+	path := t.path(req)
+	cached := t.read(path, req)
+	if cached != nil {
+		req = req.Clone(req.Context())
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := cached.Header.Get("Last-Modified"); lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		resp.Body.Close()
+		// keep the headers of the fresh response e.g. the remaining rate limit
+		for key, values := range resp.Header {
+			if key != "Content-Length" {
+				cached.Header[key] = values
+			}
+		}
+		// mark the entry as used, see evict
+		now := time.Now()
+		_ = os.Chtimes(path, now, now)
+		return cached, nil
+	}
+
+	if resp.StatusCode == http.StatusOK && (resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != "") {
+		t.write(path, req, resp)
+	}
+
+	return resp, nil
+}
+
+// path returns the cache file of req. The credentials are part of the key, as
+// the response depends on the authenticated user.
+func (t *cachingTransport) path(req *http.Request) string {
+	u := *req.URL
+	query := u.Query()
+	for _, param := range cacheIgnoredParams {
+		query.Del(param)
+	}
+	u.RawQuery = query.Encode()
+
+	hash := sha256.New()
+	for _, part := range []string{
+		u.String(),
+		req.Header.Get("Accept"),
+		req.Header.Get("Authorization"),
+		req.Header.Get("Private-Token"),
+	} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+
+	return filepath.Join(t.dir, hex.EncodeToString(hash.Sum(nil)))
+}
+
+// evict removes the cached responses which were not written or used for
+// cacheMaxAge
+func (t *cachingTransport) evict() {
+	entries, err := os.ReadDir(t.dir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() || time.Since(info.ModTime()) < cacheMaxAge {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(t.dir, entry.Name())); err != nil {
+			log.Printf("unable to evict cache entry %s: %s", entry.Name(), err)
+		}
+	}
+}
+
+// read returns the cached response at path, or nil if there is none
+func (t *cachingTransport) read(path string, req *http.Request) *http.Response {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(raw)), req)
+	if err != nil {
+		log.Printf("ignoring invalid cache entry %s: %s", path, err)
+		return nil
+	}
+
+	return resp
+}
+
+// write stores resp at path. Failing to cache a response is not fatal, as the
+// request is merely not conditional next time.
+func (t *cachingTransport) write(path string, req *http.Request, resp *http.Response) {
+	raw, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		log.Printf("unable to cache response of %s: %s", req.URL, err)
+		return
+	}
+
+	if err := os.MkdirAll(t.dir, 0o700); err != nil {
+		log.Printf("unable to create cache directory %s: %s", t.dir, err)
+		return
+	}
+
+	tmp, err := os.CreateTemp(t.dir, ".tmp-*")
+	if err != nil {
+		log.Printf("unable to cache response of %s: %s", req.URL, err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(raw)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		log.Printf("unable to cache response of %s: %s", req.URL, err)
+	}
+}
@@ -0,0 +1,109 @@
+package upstream_test
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/wmalik/ogit/mock"
+	"github.com/wmalik/ogit/upstream"
+)
+
+var _ = Describe("Caching transport", func() {
+	var client *http.Client
+	var conditions []string
+	var dir string
+	BeforeEach(func() {
+		conditions = nil
+		var err error
+		dir, err = os.MkdirTemp("", "ogit-cache")
+		Expect(err).To(BeNil())
+
+		httpClient := mock.NewHTTPClient().
+			Mock("GET", "/users/greatuser/repos",
+				func(w http.ResponseWriter, r *http.Request) {
+					conditions = append(conditions, r.Header.Get("If-None-Match"))
+					if r.Header.Get("If-None-Match") == `"v1"` {
+						w.Header().Set("X-RateLimit-Remaining", "4999")
+						w.WriteHeader(http.StatusNotModified)
+						return
+					}
+
+					w.Header().Set("ETag", `"v1"`)
+					w.Header().Set("X-RateLimit-Remaining", "5000")
+					_, _ = w.Write([]byte(`[{"name": "dotfiles"}]`))
+				},
+			).
+			Mock("GET", "/users/nocache/repos",
+				func(w http.ResponseWriter, r *http.Request) {
+					conditions = append(conditions, r.Header.Get("If-None-Match"))
+					_, _ = w.Write([]byte(`[]`))
+				},
+			).Client()
+		client = &http.Client{Transport: upstream.NewCachingTransport(dir, httpClient.Transport)}
+	})
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+	It("Returns the cached response if the resource is not modified", func() {
+		for i := 0; i < 2; i++ {
+			resp, err := client.Get("https://api.github.com/users/greatuser/repos")
+			Expect(err).To(BeNil())
+			body, err := io.ReadAll(resp.Body)
+			Expect(err).To(BeNil())
+			resp.Body.Close()
+
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(string(body)).To(Equal(`[{"name": "dotfiles"}]`))
+		}
+
+		Expect(conditions).To(Equal([]string{"", `"v1"`}))
+	})
+	It("Keeps the headers of the not modified response", func() {
+		for _, remaining := range []string{"5000", "4999"} {
+			resp, err := client.Get("https://api.github.com/users/greatuser/repos")
+			Expect(err).To(BeNil())
+			resp.Body.Close()
+			Expect(resp.Header.Get("X-RateLimit-Remaining")).To(Equal(remaining))
+		}
+	})
+	It("Does not cache responses without validators", func() {
+		for i := 0; i < 2; i++ {
+			resp, err := client.Get("https://api.github.com/users/nocache/repos")
+			Expect(err).To(BeNil())
+			resp.Body.Close()
+		}
+
+		Expect(conditions).To(Equal([]string{"", ""}))
+	})
+	It("Ignores the time of the last fetch in the cache key", func() {
+		for _, since := range []string{"2021-11-01T00:00:00Z", "2021-11-02T00:00:00Z"} {
+			resp, err := client.Get("https://api.github.com/users/greatuser/repos?last_activity_after=" + since)
+			Expect(err).To(BeNil())
+			resp.Body.Close()
+		}
+
+		Expect(conditions).To(Equal([]string{"", `"v1"`}))
+	})
+	It("Evicts the responses which were not used for a long time", func() {
+		stale := filepath.Join(dir, "stale")
+		Expect(os.WriteFile(stale, []byte("HTTP/1.1 200 OK\r\n\r\n"), 0o600)).To(Succeed())
+		old := time.Now().Add(-60 * 24 * time.Hour)
+		Expect(os.Chtimes(stale, old, old)).To(Succeed())
+
+		resp, err := client.Get("https://api.github.com/users/greatuser/repos")
+		Expect(err).To(BeNil())
+		resp.Body.Close()
+
+		_, err = os.Stat(stale)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+		entries, err := os.ReadDir(dir)
+		Expect(err).To(BeNil())
+		Expect(entries).To(HaveLen(1))
+	})
+})
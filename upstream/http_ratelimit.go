@@ -0,0 +1,186 @@
+package upstream
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimitMaxRetries is the number of times a rate limited request is retried
+const rateLimitMaxRetries = 3
+
+// rateLimitBackoff is the initial wait after hitting a rate limit which does
+// not tell when it resets, e.g. the secondary rate limit of GitHub
+const rateLimitBackoff = time.Minute
+
+// RateLimit is the API quota of the authenticated user on a provider instance
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// RateLimitReporter is implemented by RepositoryHostClient types which know
+// the API quota left after fetching repositories
+type RateLimitReporter interface {
+	// GetRateLimit returns the last reported quota, and false if the API did
+	// not report any
+	GetRateLimit() (RateLimit, bool)
+}
+
+// RateLimitTransport waits until the rate limit resets (or backs off) when a
+// request is rate limited by GitHub or GitLab, and retries it. It records the
+// quota reported by the responses.
+type RateLimitTransport struct {
+	base    http.RoundTripper
+	noRetry bool
+
+	mu        sync.Mutex
+	rateLimit *RateLimit
+}
+
+// NewRateLimitTransport returns a transport sending requests via base
+// (http.DefaultTransport if nil)
+func NewRateLimitTransport(base http.RoundTripper) *RateLimitTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &RateLimitTransport{base: base}
+}
+
+// WithoutRetries makes the transport only record the quota, for API clients
+// which retry rate limited requests themselves e.g. go-gitlab
+func (t *RateLimitTransport) WithoutRetries() *RateLimitTransport {
+	t.noRetry = true
+	return t
+}
+
+// RateLimit returns the quota reported by the last response, and false if no
+// response reported one
+func (t *RateLimitTransport) RateLimit() (RateLimit, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.rateLimit == nil {
+		return RateLimit{}, false
+	}
+	return *t.rateLimit, true
+}
+
+func (t *RateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		t.record(resp)
+		if t.noRetry {
+			return resp, nil
+		}
+
+		wait, limited := rateLimitWait(resp, attempt)
+		if !limited || attempt == rateLimitMaxRetries || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		log.Printf("[%s] rate limited, waiting %s until %s", req.URL.Host, wait.Round(time.Second), time.Now().Add(wait).Format(time.Kitchen))
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// record stores the quota reported by the headers of resp, i.e.
+// X-RateLimit-* on GitHub and RateLimit-* on GitLab
+func (t *RateLimitTransport) record(resp *http.Response) {
+	limit, remaining, reset, ok := parseRateLimit(resp.Header)
+	if !ok {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rateLimit = &RateLimit{Limit: limit, Remaining: remaining, Reset: reset}
+}
+
+// parseRateLimit returns the quota reported by header
+func parseRateLimit(header http.Header) (limit, remaining int, reset time.Time, ok bool) {
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		remainingValue := header.Get(prefix + "Remaining")
+		if remainingValue == "" {
+			continue
+		}
+
+		remaining, err := strconv.Atoi(remainingValue)
+		if err != nil {
+			return 0, 0, time.Time{}, false
+		}
+		limit, _ := strconv.Atoi(header.Get(prefix + "Limit"))
+		if resetUnix, err := strconv.ParseInt(header.Get(prefix+"Reset"), 10, 64); err == nil {
+			reset = time.Unix(resetUnix, 0)
+		}
+
+		return limit, remaining, reset, true
+	}
+
+	return 0, 0, time.Time{}, false
+}
+
+// rateLimitWait returns how long to wait before retrying the request of resp,
+// and false if resp is not rate limited. Forbidden responses are only rate
+// limited if the quota is exhausted, or if GitHub reports its secondary rate
+// limit.
+func rateLimitWait(resp *http.Response, attempt int) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if _, remaining, reset, ok := parseRateLimit(resp.Header); ok && remaining == 0 && !reset.IsZero() {
+		wait := time.Until(reset)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests || isSecondaryRateLimit(resp) {
+		return rateLimitBackoff << attempt, true
+	}
+
+	return 0, false
+}
+
+// isSecondaryRateLimit returns whether resp reports the secondary rate limit
+// of GitHub, which is only mentioned in the body of the response
+func isSecondaryRateLimit(resp *http.Response) bool {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+
+	return strings.Contains(strings.ToLower(string(body)), "secondary rate limit")
+}
@@ -0,0 +1,114 @@
+package upstream_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/wmalik/ogit/mock"
+	"github.com/wmalik/ogit/upstream"
+)
+
+var _ = Describe("Rate limit transport", func() {
+	var transport *upstream.RateLimitTransport
+	var client *http.Client
+	var requests int
+	var bodies []string
+	BeforeEach(func() {
+		requests, bodies = 0, nil
+		reset := strconv.FormatInt(time.Now().Unix(), 10)
+		httpClient := mock.NewHTTPClient().
+			Mock("GET", "/users/greatuser/repos",
+				func(w http.ResponseWriter, r *http.Request) {
+					requests++
+					w.Header().Set("X-RateLimit-Limit", "5000")
+					w.Header().Set("X-RateLimit-Reset", reset)
+					if requests == 1 {
+						w.Header().Set("X-RateLimit-Remaining", "0")
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					w.Header().Set("X-RateLimit-Remaining", "4999")
+					_, _ = w.Write([]byte(`[]`))
+				},
+			).
+			Mock("POST", "/api/v4/projects",
+				func(w http.ResponseWriter, r *http.Request) {
+					requests++
+					body, _ := io.ReadAll(r.Body)
+					bodies = append(bodies, string(body))
+					if requests == 1 {
+						w.Header().Set("Retry-After", "0")
+						w.WriteHeader(http.StatusTooManyRequests)
+						return
+					}
+					w.Header().Set("RateLimit-Limit", "2000")
+					w.Header().Set("RateLimit-Remaining", "1999")
+					_, _ = w.Write([]byte(`{}`))
+				},
+			).
+			Mock("GET", "/repos/greatuser/secret",
+				func(w http.ResponseWriter, r *http.Request) {
+					requests++
+					w.WriteHeader(http.StatusForbidden)
+				},
+			).
+			Mock("GET", "/slow",
+				func(w http.ResponseWriter, r *http.Request) {
+					requests++
+					w.Header().Set("Retry-After", "60")
+					w.WriteHeader(http.StatusTooManyRequests)
+				},
+			).Client()
+		transport = upstream.NewRateLimitTransport(httpClient.Transport)
+		client = &http.Client{Transport: transport}
+	})
+	It("Waits until the rate limit resets and records the quota", func() {
+		resp, err := client.Get("https://api.github.com/users/greatuser/repos")
+		Expect(err).To(BeNil())
+		resp.Body.Close()
+
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(requests).To(Equal(2))
+		limit, ok := transport.RateLimit()
+		Expect(ok).To(BeTrue())
+		Expect(limit.Limit).To(Equal(5000))
+		Expect(limit.Remaining).To(Equal(4999))
+	})
+	It("Retries requests with a body after Retry-After", func() {
+		resp, err := client.Post("https://gitlab.com/api/v4/projects", "application/json", strings.NewReader(`{"name": "ogit"}`))
+		Expect(err).To(BeNil())
+		resp.Body.Close()
+
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(bodies).To(Equal([]string{`{"name": "ogit"}`, `{"name": "ogit"}`}))
+		limit, _ := transport.RateLimit()
+		Expect(limit.Remaining).To(Equal(1999))
+	})
+	It("Does not retry forbidden requests which are not rate limited", func() {
+		resp, err := client.Get("https://api.github.com/repos/greatuser/secret")
+		Expect(err).To(BeNil())
+		resp.Body.Close()
+
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		Expect(requests).To(Equal(1))
+		_, ok := transport.RateLimit()
+		Expect(ok).To(BeFalse())
+	})
+	It("Stops waiting when the context is cancelled", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.github.com/slow", nil)
+		Expect(err).To(BeNil())
+
+		_, err = client.Do(req)
+		Expect(err).To(MatchError(ContainSubstring("context deadline exceeded")))
+		Expect(requests).To(Equal(1))
+	})
+})
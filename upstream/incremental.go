@@ -0,0 +1,72 @@
+package upstream
+
+import (
+	"sync"
+	"time"
+)
+
+// IncrementalClient is implemented by RepositoryHostClient types which can
+// fetch only the repositories updated since they were last fetched. Owners
+// are keyed by their name, and the authenticated user by the empty string.
+type IncrementalClient interface {
+	// SetUpdatedSince makes GetRepositories fetch only the repositories of
+	// the owners in since which were updated after since[owner]
+	SetUpdatedSince(since map[string]time.Time)
+	// UpdatedUntil returns the time of the most recent update of the
+	// repositories of each owner fetched by GetRepositories
+	UpdatedUntil() map[string]time.Time
+}
+
+// updateTracker implements IncrementalClient for the clients embedding it
+type updateTracker struct {
+	mu    sync.Mutex
+	since map[string]time.Time
+	until map[string]time.Time
+}
+
+func (t *updateTracker) SetUpdatedSince(since map[string]time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.since = since
+}
+
+func (t *updateTracker) UpdatedUntil() map[string]time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	until := map[string]time.Time{}
+	for owner, updated := range t.until {
+		until[owner] = updated
+	}
+	return until
+}
+
+// updatedSince returns the time after which the repositories of owner are
+// fetched, which is zero if all repositories are fetched
+func (t *updateTracker) updatedSince(owner string) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.since[owner]
+}
+
+// fetched records the most recent update of the repositories fetched for
+// owner, keeping the previous one if no repository was updated since
+func (t *updateTracker) fetched(owner string, repos []HostRepository) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	latest := t.since[owner]
+	for _, repo := range repos {
+		if reporter, ok := repo.(MetadataReporter); ok && reporter.GetMetadata().UpdatedAt.After(latest) {
+			latest = reporter.GetMetadata().UpdatedAt
+		}
+	}
+
+	if latest.IsZero() {
+		return
+	}
+	if t.until == nil {
+		t.until = map[string]time.Time{}
+	}
+	t.until[owner] = latest
+}
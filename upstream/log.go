@@ -20,3 +20,7 @@ func logPaginationStatus(upstream string, owner string, numRepos, remainingPages
 func logAuthenticatedUser(upstream string, username string) {
 	log.Printf("Authenticated with %s as %s", upstream, username)
 }
+
+func logPluginStatus(executable string, numRepos int) {
+	log.Printf("[%s] fetched %d repositories", executable, numRepos)
+}
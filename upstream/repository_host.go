@@ -3,6 +3,7 @@ package upstream
 import (
 	"context"
 	"fmt"
+	"time"
 )
 
 type RepositoryHostClient interface {
@@ -25,13 +26,62 @@ type HostRepository interface {
 	GetSettingsURL() string
 }
 
+// The access of the authenticated user to a repository
+const (
+	AccessUnknown = ""
+	AccessRead    = "read"
+	AccessWrite   = "write"
+)
+
+// AccessReporter is implemented by HostRepository types which know the access
+// of the authenticated user to the repository
+type AccessReporter interface {
+	GetAccess() string
+}
+
+// StarredReporter is implemented by HostRepository types which can be fetched
+// because the authenticated user starred (or watches) them
+type StarredReporter interface {
+	// IsStarred returns whether the repository was fetched only because the
+	// user starred or watches it, rather than via an owner
+	IsStarred() bool
+}
+
+// Metadata are the attributes of a repository besides its name and URLs.
+// Attributes which are not reported by the provider are left empty.
+type Metadata struct {
+	// the ID of the repository on the provider, which is kept when the
+	// repository is renamed or transferred
+	ID            string
+	Stars         int
+	Language      string
+	DefaultBranch string
+	Archived      bool
+	Fork          bool
+	// the owner/name of the repository which was forked, if known
+	Parent string
+	// e.g. public, private or internal
+	Visibility string
+	Topics     []string
+	// the size of the repository in KB
+	Size      int
+	PushedAt  time.Time
+	UpdatedAt time.Time
+}
+
+// MetadataReporter is implemented by HostRepository types whose provider
+// reports the Metadata of the repository
+type MetadataReporter interface {
+	GetMetadata() Metadata
+}
+
 type HostRepositories []HostRepository
 
 func (hr HostRepositories) DeDuplicate() []HostRepository {
 	var results []HostRepository
 	uniqueMap := map[string]HostRepository{}
 	for _, hostRepo := range hr {
-		key := fmt.Sprintf("%s/%s", hostRepo.GetOwner(), hostRepo.GetName())
+		key := fmt.Sprintf("%s/%s/%s", hostRepo.GetProvider(), hostRepo.GetOwner(), hostRepo.GetName())
 		if _, ok := uniqueMap[key]; !ok {
 			uniqueMap[key] = hostRepo
 			results = append(results, hostRepo)
@@ -40,3 +90,16 @@ func (hr HostRepositories) DeDuplicate() []HostRepository {
 
 	return results
 }
+
+// providerName returns the name under which repositories of the instance at
+// host are stored. Repositories of the public instance of a provider (e.g.
+// gitlab.com) are stored under the short name of the provider (e.g. gitlab),
+// while self-hosted instances are stored under their hostname, so that
+// repositories of different instances do not clash.
+func providerName(name, publicHost, host string) string {
+	if host == "" || host == publicHost {
+		return name
+	}
+
+	return host
+}
@@ -0,0 +1,271 @@
+package upstream
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const bitbucketPageSize = 100
+const bitbucketHost = "bitbucket.org"
+const bitbucketAPIURL = "https://api.bitbucket.org/2.0/"
+
+// BitbucketRepository is a repository as returned by the Bitbucket Cloud API
+type BitbucketRepository struct {
+	Slug        string `json:"slug"`
+	FullName    string `json:"full_name"`
+	Description string `json:"description"`
+	Workspace   struct {
+		Slug string `json:"slug"`
+	} `json:"workspace"`
+	Links struct {
+		HTML struct {
+			Href string `json:"href"`
+		} `json:"html"`
+		Clone []struct {
+			Name string `json:"name"`
+			Href string `json:"href"`
+		} `json:"clone"`
+	} `json:"links"`
+}
+
+func (r *BitbucketRepository) GetProvider() string {
+	return "bitbucket"
+}
+
+func (r *BitbucketRepository) GetName() string {
+	return r.Slug
+}
+
+func (r *BitbucketRepository) GetOwner() string {
+	return r.Workspace.Slug
+}
+
+func (r *BitbucketRepository) GetDescription() string {
+	return r.Description
+}
+
+func (r *BitbucketRepository) GetBrowserHomepageURL() string {
+	return r.Links.HTML.Href
+}
+
+func (r *BitbucketRepository) GetBrowserPullRequestsURL() string {
+	return r.Links.HTML.Href + "/pull-requests"
+}
+
+func (r *BitbucketRepository) GetOrgURL() string {
+	parsed, err := url.Parse(r.Links.HTML.Href)
+	if err != nil {
+		log.Println("unable to parse org url")
+		return ""
+	}
+	parsed.Path = filepath.Dir(parsed.Path)
+	return parsed.String()
+}
+
+func (r *BitbucketRepository) GetIssuesURL() string {
+	return r.Links.HTML.Href + "/issues"
+}
+
+func (r *BitbucketRepository) GetCIURL() string {
+	return r.Links.HTML.Href + "/pipelines"
+}
+
+// GetReleasesURL returns the URL of the downloads page, as Bitbucket does not
+// support releases
+func (r *BitbucketRepository) GetReleasesURL() string {
+	return r.Links.HTML.Href + "/downloads"
+}
+
+func (r *BitbucketRepository) GetSettingsURL() string {
+	return r.Links.HTML.Href + "/admin"
+}
+
+// GetHTTPSCloneURL returns the HTTPS clone URL without the username, which
+// Bitbucket adds for the authenticated user
+func (r *BitbucketRepository) GetHTTPSCloneURL() string {
+	parsed, err := url.Parse(r.cloneURL("https"))
+	if err != nil {
+		log.Println("unable to parse https clone url")
+		return ""
+	}
+	parsed.User = nil
+	return parsed.String()
+}
+
+func (r *BitbucketRepository) GetSSHCloneURL() string {
+	return r.cloneURL("ssh")
+}
+
+func (r *BitbucketRepository) cloneURL(name string) string {
+	for _, link := range r.Links.Clone {
+		if link.Name == name {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+// bitbucketPage is a page of results of the Bitbucket Cloud API
+type bitbucketPage struct {
+	Values  []*BitbucketRepository `json:"values"`
+	Next    string                 `json:"next"`
+	Page    int                    `json:"page"`
+	Size    int                    `json:"size"`
+	PageLen int                    `json:"pagelen"`
+}
+
+// BitbucketClient fetches repositories of Bitbucket Cloud workspaces
+type BitbucketClient struct {
+	fetchResults
+	client   *http.Client
+	baseURL  *url.URL
+	username string
+}
+
+func NewBitbucketClient(client *http.Client) *BitbucketClient {
+	baseURL, _ := url.Parse(bitbucketAPIURL)
+	return &BitbucketClient{client: client, baseURL: baseURL, username: "nobody"}
+}
+
+// NewBitbucketClientWithToken returns a client authenticated with an access
+// token, or with an app password if username is not empty
+func NewBitbucketClientWithToken(token, username string) *BitbucketClient {
+	if token == "" {
+		return NewBitbucketClient(http.DefaultClient)
+	}
+
+	if username != "" {
+		return NewBitbucketClient(&http.Client{
+			Transport: &basicAuthTransport{username: username, password: token},
+		})
+	}
+
+	return NewBitbucketClient(
+		oauth2.NewClient(
+			context.Background(),
+			oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}),
+		),
+	)
+}
+
+// GetRepositories fetches the repositories of the workspaces concurrently.
+// When fetching the repositories of some workspaces fails, the repositories of
+// the other workspaces are returned along with a PartialError.
+func (c *BitbucketClient) GetRepositories(ctx context.Context, workspaces []string, fetchUserRepos bool) ([]HostRepository, error) {
+	res := HostRepositories{}
+	var m sync.Map
+
+	// workspace and repository access tokens are not associated with a
+	// user, so only the user's repositories are skipped if the user
+	// information is unavailable
+	if err := c.setUserInfo(ctx); err != nil && fetchUserRepos {
+		if len(workspaces) == 0 {
+			return nil, err
+		}
+		log.Printf("[%s] skipping the repositories of the user: %s", bitbucketHost, err)
+		fetchUserRepos = false
+	}
+
+	logAuthenticatedUser(bitbucketHost, c.username)
+	c.reset()
+
+	if fetchUserRepos {
+		// the personal workspace of a user has the same slug as the username
+		workspaces = append(workspaces, c.username)
+	}
+
+	var wg sync.WaitGroup
+	for _, workspace := range workspaces {
+		wg.Add(1)
+		go func(workspace string) {
+			defer wg.Done()
+			repos, err := c.getRepositoriesForWorkspace(ctx, workspace)
+			c.record(workspace, false, time.Time{}, repos, err)
+			if err != nil {
+				return
+			}
+
+			m.Store(workspace, repos)
+		}(workspace)
+	}
+
+	wg.Wait()
+
+	m.Range(func(key, value interface{}) bool {
+		res = append(res, value.([]HostRepository)...)
+		return true
+	})
+
+	return res.DeDuplicate(), c.err()
+}
+
+func (c *BitbucketClient) getRepositoriesForWorkspace(ctx context.Context, workspace string) ([]HostRepository, error) {
+	u, err := c.baseURL.Parse("repositories/" + url.PathEscape(workspace))
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = url.Values{"pagelen": {strconv.Itoa(bitbucketPageSize)}}.Encode()
+
+	var reposAcc []*BitbucketRepository
+	next := u.String()
+	for next != "" {
+		var page bitbucketPage
+		if _, err := getJSON(ctx, c.client, next, &page); err != nil {
+			return nil, err
+		}
+
+		remainingPages := 0
+		if page.PageLen > 0 && page.Page > 0 {
+			remainingPages = (page.Size+page.PageLen-1)/page.PageLen - page.Page
+		}
+		logPaginationStatus(bitbucketHost, workspace, len(page.Values), remainingPages, "n/a")
+
+		reposAcc = append(reposAcc, page.Values...)
+		next = page.Next
+	}
+
+	repos := make([]HostRepository, len(reposAcc))
+	for i, r := range reposAcc {
+		repos[i] = r
+	}
+	return repos, nil
+}
+
+// setUserInfo fetches the authenticated user's information and stores it
+func (c *BitbucketClient) setUserInfo(ctx context.Context) error {
+	u, err := c.baseURL.Parse("user")
+	if err != nil {
+		return err
+	}
+
+	var user struct {
+		Username string `json:"username"`
+	}
+	if _, err := getJSON(ctx, c.client, u.String(), &user); err != nil {
+		log.Println("Unable to get user information, perhaps a bitbucket token is not set?")
+		return err
+	}
+
+	c.username = user.Username
+	return nil
+}
+
+// basicAuthTransport authenticates requests with HTTP basic auth
+type basicAuthTransport struct {
+	username string
+	password string
+}
+
+func (t *basicAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.SetBasicAuth(t.username, t.password)
+	return http.DefaultTransport.RoundTrip(req)
+}
@@ -0,0 +1,165 @@
+package upstream_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/wmalik/ogit/mock"
+	"github.com/wmalik/ogit/upstream"
+)
+
+var _ = Describe("Bitbucket repo", func() {
+	var client *upstream.BitbucketClient
+	var repositories []upstream.HostRepository
+	var err error
+	BeforeEach(func() {
+		httpClient := mock.NewHTTPClient().
+			Mock("GET", "/2.0/user",
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`{"username": "john_smith", "display_name": "John Smith"}`))
+				},
+			).
+			Mock("GET", "/2.0/repositories/greatworkspace",
+				func(w http.ResponseWriter, r *http.Request) {
+					if r.URL.Query().Get("page") == "2" {
+						_, _ = w.Write([]byte(`
+							{
+							  "page": 2,
+							  "pagelen": 1,
+							  "size": 2,
+							  "values": [
+								{
+								  "slug": "personal-website",
+								  "full_name": "greatworkspace/personal-website",
+								  "description": "my personal website",
+								  "workspace": {"slug": "greatworkspace"},
+								  "links": {
+									"html": {"href": "https://bitbucket.org/greatworkspace/personal-website"}
+								  }
+								}
+							  ]
+							}`,
+						))
+						return
+					}
+					_, _ = w.Write([]byte(`
+						{
+						  "page": 1,
+						  "pagelen": 1,
+						  "size": 2,
+						  "next": "https://api.bitbucket.org/2.0/repositories/greatworkspace?page=2",
+						  "values": [
+							{
+							  "slug": "dotfiles",
+							  "full_name": "greatworkspace/dotfiles",
+							  "description": "my dotfiles",
+							  "workspace": {"slug": "greatworkspace"},
+							  "links": {
+								"html": {"href": "https://bitbucket.org/greatworkspace/dotfiles"},
+								"clone": [
+								  {"name": "https", "href": "https://john_smith@bitbucket.org/greatworkspace/dotfiles.git"},
+								  {"name": "ssh", "href": "git@bitbucket.org:greatworkspace/dotfiles.git"}
+								]
+							  }
+							}
+						  ]
+						}`,
+					))
+				},
+			).Client()
+		client = upstream.NewBitbucketClient(httpClient)
+		repositories, err = client.GetRepositories(context.Background(), []string{"greatworkspace"}, false)
+		Expect(err).To(BeNil())
+	})
+	It("Returns the repositories of all pages of a workspace", func() {
+		Expect(len(repositories)).To(Equal(2))
+		Expect(repositories[0].GetName()).To(Equal("dotfiles"))
+		Expect(repositories[1].GetName()).To(Equal("personal-website"))
+		Expect(repositories[1].GetDescription()).To(Equal("my personal website"))
+	})
+	It("Returns the attributes of a repository", func() {
+		Expect(repositories[0].GetProvider()).To(Equal("bitbucket"))
+		Expect(repositories[0].GetOwner()).To(Equal("greatworkspace"))
+		Expect(repositories[0].GetDescription()).To(Equal("my dotfiles"))
+		Expect(repositories[0].GetBrowserHomepageURL()).To(Equal("https://bitbucket.org/greatworkspace/dotfiles"))
+		Expect(repositories[0].GetBrowserPullRequestsURL()).To(Equal("https://bitbucket.org/greatworkspace/dotfiles/pull-requests"))
+		Expect(repositories[0].GetOrgURL()).To(Equal("https://bitbucket.org/greatworkspace"))
+		Expect(repositories[0].GetIssuesURL()).To(Equal("https://bitbucket.org/greatworkspace/dotfiles/issues"))
+		Expect(repositories[0].GetCIURL()).To(Equal("https://bitbucket.org/greatworkspace/dotfiles/pipelines"))
+		Expect(repositories[0].GetReleasesURL()).To(Equal("https://bitbucket.org/greatworkspace/dotfiles/downloads"))
+		Expect(repositories[0].GetSettingsURL()).To(Equal("https://bitbucket.org/greatworkspace/dotfiles/admin"))
+		Expect(repositories[0].GetHTTPSCloneURL()).To(Equal("https://bitbucket.org/greatworkspace/dotfiles.git"))
+		Expect(repositories[0].GetSSHCloneURL()).To(Equal("git@bitbucket.org:greatworkspace/dotfiles.git"))
+	})
+})
+
+var _ = Describe("Bitbucket repo with a workspace access token", func() {
+	var client *upstream.BitbucketClient
+	var repositories []upstream.HostRepository
+	var err error
+	BeforeEach(func() {
+		httpClient := mock.NewHTTPClient().
+			Mock("GET", "/2.0/user",
+				func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusUnauthorized)
+				},
+			).
+			Mock("GET", "/2.0/repositories/greatworkspace",
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`{"values": [{"slug": "dotfiles", "name": "dotfiles", "workspace": {"slug": "greatworkspace"}}]}`))
+				},
+			).Client()
+		client = upstream.NewBitbucketClient(httpClient)
+		repositories, err = client.GetRepositories(context.Background(), []string{"greatworkspace"}, true)
+	})
+	It("Skips the repositories of the user", func() {
+		Expect(err).To(BeNil())
+		Expect(len(repositories)).To(Equal(1))
+		Expect(repositories[0].GetName()).To(Equal("dotfiles"))
+	})
+	It("Only reports the workspaces as fetched", func() {
+		results := client.FetchResults()
+		Expect(results).To(HaveLen(1))
+		Expect(results[0].Owner).To(Equal("greatworkspace"))
+		Expect(results[0].Complete).To(BeTrue())
+	})
+})
+
+var _ = Describe("Bitbucket repo with an inaccessible workspace", func() {
+	var client *upstream.BitbucketClient
+	var repositories []upstream.HostRepository
+	var err error
+	BeforeEach(func() {
+		httpClient := mock.NewHTTPClient().
+			Mock("GET", "/2.0/user",
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`{"username": "john_smith"}`))
+				},
+			).
+			Mock("GET", "/2.0/repositories/greatworkspace",
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`{"values": [{"slug": "dotfiles", "workspace": {"slug": "greatworkspace"}}]}`))
+				},
+			).
+			Mock("GET", "/2.0/repositories/secretworkspace",
+				func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusForbidden)
+				},
+			).Client()
+		client = upstream.NewBitbucketClient(httpClient)
+		repositories, err = client.GetRepositories(context.Background(), []string{"greatworkspace", "secretworkspace"}, false)
+	})
+	It("Returns the repositories of the other workspaces along with the failure", func() {
+		var partial *upstream.PartialError
+		Expect(errors.As(err, &partial)).To(BeTrue())
+		Expect(partial.Failed).To(HaveLen(1))
+		Expect(partial.Failed[0].Owner).To(Equal("secretworkspace"))
+		Expect(len(repositories)).To(Equal(1))
+		Expect(repositories[0].GetName()).To(Equal("dotfiles"))
+		Expect(client.FetchResults()[0].Complete).To(BeTrue())
+	})
+})
@@ -0,0 +1,286 @@
+package upstream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gerritJSONPrefix is prepended by Gerrit to all JSON responses to prevent
+// cross-site script inclusion
+const gerritJSONPrefix = ")]}'"
+
+// gerritSSHPort is the default port of the SSH daemon of Gerrit
+const gerritSSHPort = 29418
+
+// GerritProject is a project as returned by the Gerrit REST API
+type GerritProject struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+	State       string `json:"state"`
+	WebLinks    []struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	} `json:"web_links"`
+
+	name     string
+	provider string
+	baseURL  *url.URL
+	// the username of the SSH clone URL, if any
+	username string
+	// whether the HTTPS clone URL requires authentication
+	authenticated bool
+}
+
+func (r *GerritProject) GetProvider() string {
+	return r.provider
+}
+
+func (r *GerritProject) GetName() string {
+	return path.Base(r.name)
+}
+
+// GetOwner returns the folder of the project e.g. platform/frameworks for
+// platform/frameworks/base
+// GetOwner returns the folder of the project, or an empty string for projects
+// which are not nested in a folder (e.g. "gerrit"), as any name could clash
+// with a folder
+func (r *GerritProject) GetOwner() string {
+	owner := path.Dir(r.name)
+	if owner == "." {
+		return ""
+	}
+	return owner
+}
+
+func (r *GerritProject) GetDescription() string {
+	return r.Description
+}
+
+// GetBrowserHomepageURL returns the first web link (e.g. gitiles) of the
+// project, or the project page of Gerrit if there are none
+func (r *GerritProject) GetBrowserHomepageURL() string {
+	for _, link := range r.WebLinks {
+		if strings.HasPrefix(link.URL, "http") {
+			return link.URL
+		}
+	}
+	return r.url("admin/repos/" + r.name)
+}
+
+// GetBrowserPullRequestsURL returns the URL of the open changes of the project
+func (r *GerritProject) GetBrowserPullRequestsURL() string {
+	return r.url("q/project:" + r.name + "+status:open")
+}
+
+func (r *GerritProject) GetOrgURL() string {
+	if r.GetOwner() == "" {
+		return r.url("admin/repos")
+	}
+	return r.url("admin/repos/q/filter:" + r.GetOwner())
+}
+
+// GetIssuesURL returns an empty string, as Gerrit has no issue tracker
+func (r *GerritProject) GetIssuesURL() string {
+	return ""
+}
+
+// GetCIURL returns an empty string, as CI is not part of Gerrit
+func (r *GerritProject) GetCIURL() string {
+	return ""
+}
+
+// GetReleasesURL returns the URL of the tags of the project
+func (r *GerritProject) GetReleasesURL() string {
+	return r.url("admin/repos/" + r.name + ",tags")
+}
+
+func (r *GerritProject) GetSettingsURL() string {
+	return r.url("admin/repos/" + r.name)
+}
+
+// GetHTTPSCloneURL returns the authenticated URL (prefixed with /a/) of the
+// project if the client is authenticated, as Gerrit only serves private
+// projects there
+func (r *GerritProject) GetHTTPSCloneURL() string {
+	if r.authenticated {
+		return r.url("a/" + r.name)
+	}
+	return r.url(r.name)
+}
+
+func (r *GerritProject) GetSSHCloneURL() string {
+	u := url.URL{
+		Scheme: "ssh",
+		Host:   fmt.Sprintf("%s:%d", r.baseURL.Hostname(), gerritSSHPort),
+		Path:   "/" + r.name,
+	}
+	if r.username != "" {
+		u.User = url.User(r.username)
+	}
+	return u.String()
+}
+
+// url returns the URL of the given path relative to the Gerrit instance
+func (r *GerritProject) url(p string) string {
+	u := *r.baseURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + p
+	return u.String()
+}
+
+// GerritClient fetches projects from a Gerrit instance
+type GerritClient struct {
+	fetchResults
+	client        *http.Client
+	baseURL       *url.URL
+	provider      string
+	authenticated bool
+	username      string
+}
+
+// NewGerritClient returns a client of the Gerrit instance at baseURL. If
+// authenticated is true, the client uses the authenticated REST endpoints
+// (prefixed with /a/), which require client to provide credentials.
+func NewGerritClient(client *http.Client, baseURL string, authenticated bool) (*GerritClient, error) {
+	parsed, err := url.Parse(strings.TrimSuffix(baseURL, "/") + "/")
+	if err != nil {
+		return nil, err
+	}
+
+	if parsed.Host == "" {
+		return nil, fmt.Errorf("invalid gerrit base url: %q", baseURL)
+	}
+
+	return &GerritClient{
+		client:        client,
+		baseURL:       parsed,
+		provider:      parsed.Hostname(),
+		authenticated: authenticated,
+	}, nil
+}
+
+// NewGerritClientWithToken returns a client authenticated with the HTTP
+// password of username
+func NewGerritClientWithToken(baseURL, username, token string) (*GerritClient, error) {
+	if token == "" {
+		client, err := NewGerritClient(http.DefaultClient, baseURL, false)
+		if err != nil {
+			return nil, err
+		}
+		return client.WithUsername(username), nil
+	}
+
+	client, err := NewGerritClient(
+		&http.Client{
+			Transport: &basicAuthTransport{username: username, password: token},
+		},
+		baseURL,
+		true,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.WithUsername(username), nil
+}
+
+// WithUsername makes the client return clone URLs for username, which is
+// required by the SSH daemon of Gerrit
+func (c *GerritClient) WithUsername(username string) *GerritClient {
+	c.username = username
+	return c
+}
+
+// GetRepositories returns the projects whose names start with one of the
+// prefixes. Gerrit has no notion of user repositories, so fetchUserRepos is
+// ignored. When fetching the projects of some prefixes fails, the projects of
+// the other prefixes are returned along with a PartialError.
+func (c *GerritClient) GetRepositories(ctx context.Context, prefixes []string, fetchUserRepos bool) ([]HostRepository, error) {
+	res := HostRepositories{}
+	var m sync.Map
+
+	c.reset()
+
+	var wg sync.WaitGroup
+	for _, prefix := range prefixes {
+		wg.Add(1)
+		go func(prefix string) {
+			defer wg.Done()
+			repos, err := c.getProjects(ctx, prefix)
+			// the projects of a prefix include the ones of the folders
+			// below it
+			c.record(strings.TrimSuffix(prefix, "/"), false, time.Time{}, repos, err)
+			if err != nil {
+				return
+			}
+
+			m.Store(prefix, repos)
+		}(prefix)
+	}
+
+	wg.Wait()
+
+	m.Range(func(key, value interface{}) bool {
+		res = append(res, value.([]HostRepository)...)
+		return true
+	})
+
+	return res.DeDuplicate(), c.err()
+}
+
+// getProjects fetches the visible projects whose names start with prefix
+func (c *GerritClient) getProjects(ctx context.Context, prefix string) ([]HostRepository, error) {
+	endpoint := "projects/"
+	if c.authenticated {
+		endpoint = "a/projects/"
+	}
+
+	u, err := c.baseURL.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = url.Values{"d": {""}, "p": {prefix}}.Encode()
+
+	body, _, err := get(ctx, c.client, u.String())
+	if err != nil {
+		return nil, err
+	}
+
+	var projects map[string]*GerritProject
+	if err := json.Unmarshal(bytes.TrimPrefix(body, []byte(gerritJSONPrefix)), &projects); err != nil {
+		return nil, err
+	}
+
+	logPaginationStatus(c.provider, prefix, len(projects), 0, "n/a")
+
+	// the projects are returned as a map, sort them for a stable order
+	names := make([]string, 0, len(projects))
+	for name, project := range projects {
+		if project.State == "HIDDEN" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	repos := make([]HostRepository, len(names))
+	for i, name := range names {
+		project := projects[name]
+		project.name = name
+		project.provider = c.provider
+		project.baseURL = c.baseURL
+		project.username = c.username
+		project.authenticated = c.authenticated
+		repos[i] = project
+	}
+
+	return repos, nil
+}
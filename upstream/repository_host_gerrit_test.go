@@ -0,0 +1,151 @@
+package upstream_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/wmalik/ogit/mock"
+	"github.com/wmalik/ogit/upstream"
+)
+
+var _ = Describe("Gerrit repo", func() {
+	var client *upstream.GerritClient
+	var repositories []upstream.HostRepository
+	var prefix string
+	var err error
+	BeforeEach(func() {
+		httpClient := mock.NewHTTPClient().
+			Mock("GET", "/r/projects/",
+				func(w http.ResponseWriter, r *http.Request) {
+					prefix = r.URL.Query().Get("p")
+					_, _ = w.Write([]byte(`)]}'
+						{
+						  "platform/tools/repo": {
+							"id": "platform%2Ftools%2Frepo",
+							"description": "the repo tool",
+							"state": "ACTIVE",
+							"web_links": [
+							  {"name": "gitiles", "url": "https://review.example.org/plugins/gitiles/platform/tools/repo"}
+							]
+						  },
+						  "platform/build": {
+							"id": "platform%2Fbuild",
+							"state": "ACTIVE"
+						  },
+						  "platform/secret": {
+							"id": "platform%2Fsecret",
+							"state": "HIDDEN"
+						  }
+						}`,
+					))
+				},
+			).Client()
+		client, err = upstream.NewGerritClient(httpClient, "https://review.example.org/r", false)
+		Expect(err).To(BeNil())
+		repositories, err = client.GetRepositories(context.Background(), []string{"platform/"}, true)
+		Expect(err).To(BeNil())
+	})
+	It("Returns the visible projects matching the prefix", func() {
+		Expect(prefix).To(Equal("platform/"))
+		Expect(len(repositories)).To(Equal(2))
+		Expect(repositories[0].GetOwner()).To(Equal("platform"))
+		Expect(repositories[0].GetName()).To(Equal("build"))
+		Expect(repositories[1].GetOwner()).To(Equal("platform/tools"))
+		Expect(repositories[1].GetName()).To(Equal("repo"))
+	})
+	It("Returns the attributes of a project", func() {
+		Expect(repositories[1].GetProvider()).To(Equal("review.example.org"))
+		Expect(repositories[1].GetDescription()).To(Equal("the repo tool"))
+		Expect(repositories[1].GetBrowserHomepageURL()).To(Equal("https://review.example.org/plugins/gitiles/platform/tools/repo"))
+		Expect(repositories[1].GetBrowserPullRequestsURL()).To(Equal("https://review.example.org/r/q/project:platform/tools/repo+status:open"))
+		Expect(repositories[1].GetSettingsURL()).To(Equal("https://review.example.org/r/admin/repos/platform/tools/repo"))
+		Expect(repositories[1].GetHTTPSCloneURL()).To(Equal("https://review.example.org/r/platform/tools/repo"))
+		Expect(repositories[1].GetSSHCloneURL()).To(Equal("ssh://review.example.org:29418/platform/tools/repo"))
+		Expect(repositories[0].GetBrowserHomepageURL()).To(Equal("https://review.example.org/r/admin/repos/platform/build"))
+	})
+
+	Context("When the client is authenticated", func() {
+		BeforeEach(func() {
+			httpClient := mock.NewHTTPClient().
+				Mock("GET", "/r/a/projects/",
+					func(w http.ResponseWriter, r *http.Request) {
+						_, _ = w.Write([]byte(`)]}'
+							{"platform/secret": {"id": "platform%2Fsecret", "state": "ACTIVE"}}`,
+						))
+					},
+				).Client()
+			client, err = upstream.NewGerritClient(httpClient, "https://review.example.org/r", true)
+			Expect(err).To(BeNil())
+			repositories, err = client.WithUsername("alice").GetRepositories(context.Background(), []string{"platform/"}, true)
+			Expect(err).To(BeNil())
+		})
+		It("Returns the clone URLs of the user", func() {
+			Expect(len(repositories)).To(Equal(1))
+			Expect(repositories[0].GetHTTPSCloneURL()).To(Equal("https://review.example.org/r/a/platform/secret"))
+			Expect(repositories[0].GetSSHCloneURL()).To(Equal("ssh://alice@review.example.org:29418/platform/secret"))
+		})
+	})
+	Context("When fetching the projects of a prefix fails", func() {
+		BeforeEach(func() {
+			httpClient := mock.NewHTTPClient().
+				Mock("GET", "/r/projects/",
+					func(w http.ResponseWriter, r *http.Request) {
+						if r.URL.Query().Get("p") == "broken/" {
+							w.WriteHeader(http.StatusInternalServerError)
+							return
+						}
+						_, _ = w.Write([]byte(`)]}'
+							{"platform/build": {"id": "platform%2Fbuild", "state": "ACTIVE"}}`,
+						))
+					},
+				).Client()
+			client, err = upstream.NewGerritClient(httpClient, "https://review.example.org/r", false)
+			Expect(err).To(BeNil())
+			repositories, err = client.GetRepositories(context.Background(), []string{"platform/", "broken/"}, false)
+		})
+		It("Returns the projects of the other prefixes along with the failure", func() {
+			var partial *upstream.PartialError
+			Expect(errors.As(err, &partial)).To(BeTrue())
+			Expect(partial.Failed).To(HaveLen(1))
+			Expect(partial.Failed[0].Owner).To(Equal("broken"))
+			Expect(len(repositories)).To(Equal(1))
+			Expect(repositories[0].GetName()).To(Equal("build"))
+
+			results := client.FetchResults()
+			Expect(results).To(HaveLen(2))
+			Expect(results[1].Owner).To(Equal("platform"))
+			Expect(results[1].Complete).To(BeTrue())
+		})
+	})
+	Context("When projects are not nested in a folder", func() {
+		BeforeEach(func() {
+			httpClient := mock.NewHTTPClient().
+				Mock("GET", "/r/projects/",
+					func(w http.ResponseWriter, r *http.Request) {
+						_, _ = w.Write([]byte(`)]}'
+							{
+							  "gerrit": {"id": "gerrit", "state": "ACTIVE"},
+							  "projects/gerrit": {"id": "projects%2Fgerrit", "state": "ACTIVE"}
+							}`,
+						))
+					},
+				).Client()
+			client, err = upstream.NewGerritClient(httpClient, "https://review.example.org/r", false)
+			Expect(err).To(BeNil())
+			repositories, err = client.GetRepositories(context.Background(), []string{""}, false)
+			Expect(err).To(BeNil())
+		})
+		It("Returns them without owner, apart from the projects of a folder of the same name", func() {
+			Expect(len(repositories)).To(Equal(2))
+			Expect(repositories[0].GetOwner()).To(Equal(""))
+			Expect(repositories[0].GetName()).To(Equal("gerrit"))
+			Expect(repositories[0].GetOrgURL()).To(Equal("https://review.example.org/r/admin/repos"))
+			Expect(repositories[1].GetOwner()).To(Equal("projects"))
+			Expect(repositories[1].GetName()).To(Equal("gerrit"))
+		})
+	})
+})
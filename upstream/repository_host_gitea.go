@@ -0,0 +1,257 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// giteaPageSize is the default maximum page size (MAX_RESPONSE_ITEMS) of
+// Gitea, requesting more results in truncated pages
+const giteaPageSize = 50
+
+// GiteaRepository is a repository as returned by the API of Gitea and its
+// forks (e.g. Forgejo, Codeberg)
+type GiteaRepository struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	FullName    string `json:"full_name"`
+	Description string `json:"description"`
+	HTMLURL     string `json:"html_url"`
+	SSHURL      string `json:"ssh_url"`
+	CloneURL    string `json:"clone_url"`
+	Owner       struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+
+	provider string
+}
+
+func (r *GiteaRepository) GetProvider() string {
+	return r.provider
+}
+
+func (r *GiteaRepository) GetName() string {
+	return r.Name
+}
+
+func (r *GiteaRepository) GetOwner() string {
+	return r.Owner.Login
+}
+
+func (r *GiteaRepository) GetDescription() string {
+	return r.Description
+}
+
+func (r *GiteaRepository) GetBrowserHomepageURL() string {
+	return r.HTMLURL
+}
+
+func (r *GiteaRepository) GetBrowserPullRequestsURL() string {
+	return r.HTMLURL + "/pulls"
+}
+
+func (r *GiteaRepository) GetOrgURL() string {
+	parsed, err := url.Parse(r.HTMLURL)
+	if err != nil {
+		log.Println("unable to parse org url")
+		return ""
+	}
+	parsed.Path = filepath.Dir(parsed.Path)
+	return parsed.String()
+}
+
+func (r *GiteaRepository) GetIssuesURL() string {
+	return r.HTMLURL + "/issues"
+}
+
+func (r *GiteaRepository) GetCIURL() string {
+	return r.HTMLURL + "/actions"
+}
+
+func (r *GiteaRepository) GetReleasesURL() string {
+	return r.HTMLURL + "/releases"
+}
+
+func (r *GiteaRepository) GetSettingsURL() string {
+	return r.HTMLURL + "/settings"
+}
+
+func (r *GiteaRepository) GetHTTPSCloneURL() string {
+	return r.CloneURL
+}
+
+func (r *GiteaRepository) GetSSHCloneURL() string {
+	return r.SSHURL
+}
+
+// GiteaClient fetches repositories from a Gitea compatible instance
+type GiteaClient struct {
+	fetchResults
+	client   *http.Client
+	baseURL  *url.URL
+	provider string
+	username string
+}
+
+func NewGiteaClient(client *http.Client, baseURL string) (*GiteaClient, error) {
+	parsed, err := url.Parse(strings.TrimSuffix(baseURL, "/") + "/api/v1/")
+	if err != nil {
+		return nil, err
+	}
+
+	if parsed.Host == "" {
+		return nil, fmt.Errorf("invalid gitea base url: %q", baseURL)
+	}
+
+	return &GiteaClient{
+		client:   client,
+		baseURL:  parsed,
+		provider: parsed.Hostname(),
+		username: "nobody",
+	}, nil
+}
+
+func NewGiteaClientWithToken(baseURL, token string) (*GiteaClient, error) {
+	if token == "" {
+		return NewGiteaClient(http.DefaultClient, baseURL)
+	}
+
+	return NewGiteaClient(
+		oauth2.NewClient(
+			context.Background(),
+			oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}),
+		),
+		baseURL,
+	)
+}
+
+// GetRepositories fetches the repositories of the owners concurrently. When
+// fetching the repositories of some owners fails, the repositories of the
+// other owners are returned along with a PartialError.
+func (c *GiteaClient) GetRepositories(ctx context.Context, owners []string, fetchUserRepos bool) ([]HostRepository, error) {
+	res := HostRepositories{}
+	var m sync.Map
+
+	if err := c.setUserInfo(ctx); err != nil {
+		return nil, err
+	}
+
+	logAuthenticatedUser(c.provider, c.username)
+	c.reset()
+
+	var wg sync.WaitGroup
+	if fetchUserRepos {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			repos, err := c.getRepositories(ctx, "user/repos", "")
+			c.record(c.username, false, time.Time{}, repos, err)
+			if err != nil {
+				return
+			}
+
+			m.Store("", repos)
+		}()
+	}
+
+	for _, owner := range owners {
+		wg.Add(1)
+		go func(owner string) {
+			defer wg.Done()
+			repos, err := c.getRepositories(ctx, "orgs/"+url.PathEscape(owner)+"/repos", owner)
+			if errors.Is(err, errNotFound) {
+				repos, err = c.getRepositories(ctx, "users/"+url.PathEscape(owner)+"/repos", owner)
+			}
+			c.record(owner, false, time.Time{}, repos, err)
+			if err != nil {
+				return
+			}
+
+			m.Store(owner, repos)
+		}(owner)
+	}
+
+	wg.Wait()
+
+	m.Range(func(key, value interface{}) bool {
+		res = append(res, value.([]HostRepository)...)
+		return true
+	})
+
+	return res.DeDuplicate(), c.err()
+}
+
+// getRepositories fetches all pages of a repository listing endpoint
+func (c *GiteaClient) getRepositories(ctx context.Context, endpoint string, owner string) ([]HostRepository, error) {
+	var reposAcc []*GiteaRepository
+	for page := 1; ; page++ {
+		var repos []*GiteaRepository
+		query := url.Values{}
+		query.Set("page", fmt.Sprint(page))
+		query.Set("limit", fmt.Sprint(giteaPageSize))
+		header, err := c.get(ctx, endpoint, query, &repos)
+		if err != nil {
+			return nil, err
+		}
+
+		reposAcc = append(reposAcc, repos...)
+
+		// the server returns fewer repositories per page than requested if
+		// its MAX_RESPONSE_ITEMS is lower, so only the total count or an
+		// empty page end the listing
+		total, err := strconv.Atoi(header.Get("X-Total-Count"))
+		remainingPages := 0
+		if err == nil && len(repos) > 0 && total > len(reposAcc) {
+			remainingPages = (total - len(reposAcc) + len(repos) - 1) / len(repos)
+		}
+		logPaginationStatus(c.provider, owner, len(repos), remainingPages, "n/a")
+
+		if len(repos) == 0 || (err == nil && len(reposAcc) >= total) {
+			break
+		}
+	}
+
+	repos := make([]HostRepository, len(reposAcc))
+	for i, r := range reposAcc {
+		r.provider = c.provider
+		repos[i] = r
+	}
+	return repos, nil
+}
+
+// get performs a GET request against the Gitea API, decodes the JSON
+// response into v and returns the response headers
+func (c *GiteaClient) get(ctx context.Context, endpoint string, query url.Values, v interface{}) (http.Header, error) {
+	u, err := c.baseURL.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = query.Encode()
+
+	return getJSON(ctx, c.client, u.String(), v)
+}
+
+// setUserInfo fetches the authenticated user's information and stores it
+func (c *GiteaClient) setUserInfo(ctx context.Context) error {
+	var user struct {
+		Login string `json:"login"`
+	}
+	if _, err := c.get(ctx, "user", nil, &user); err != nil {
+		log.Println("Unable to get user information, perhaps a gitea token is not set?")
+		return err
+	}
+
+	c.username = user.Login
+	return nil
+}
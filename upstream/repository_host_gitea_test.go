@@ -0,0 +1,152 @@
+package upstream_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/wmalik/ogit/mock"
+	"github.com/wmalik/ogit/upstream"
+)
+
+var _ = Describe("Gitea repo", func() {
+	var client *upstream.GiteaClient
+	var repositories []upstream.HostRepository
+	var err error
+	BeforeEach(func() {
+		httpClient := mock.NewHTTPClient().
+			Mock("GET", "/api/v1/user",
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`
+						{
+						  "id": 1,
+						  "login": "john_smith",
+						  "full_name": "John Smith",
+						  "email": "john@example.com"
+						}`,
+					))
+				},
+			).
+			Mock("GET", "/api/v1/orgs/greatorg/repos",
+				func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("X-Total-Count", "2")
+					_, _ = w.Write([]byte(`
+						[
+						  {
+							"id": 9,
+							"owner": {"login": "greatorg"},
+							"name": "dotfiles",
+							"full_name": "greatorg/dotfiles",
+							"description": "my dotfiles",
+							"html_url": "https://codeberg.org/greatorg/dotfiles",
+							"ssh_url": "git@codeberg.org:greatorg/dotfiles.git",
+							"clone_url": "https://codeberg.org/greatorg/dotfiles.git"
+						  },
+						  {
+							"id": 10,
+							"owner": {"login": "greatorg"},
+							"name": "personal-website",
+							"full_name": "greatorg/personal-website",
+							"description": "my personal website",
+							"html_url": "https://codeberg.org/greatorg/personal-website",
+							"ssh_url": "git@codeberg.org:greatorg/personal-website.git",
+							"clone_url": "https://codeberg.org/greatorg/personal-website.git"
+						  }
+						]`,
+					))
+				},
+			).
+			Mock("GET", "/api/v1/orgs/greatuser/repos",
+				func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusNotFound)
+				},
+			).
+			Mock("GET", "/api/v1/users/greatuser/repos",
+				func(w http.ResponseWriter, r *http.Request) {
+					// without X-Total-Count, the listing ends with an empty page
+					if r.URL.Query().Get("page") != "1" {
+						_, _ = w.Write([]byte(`[]`))
+						return
+					}
+					_, _ = w.Write([]byte(`
+						[
+						  {
+							"id": 11,
+							"owner": {"login": "greatuser"},
+							"name": "notes",
+							"full_name": "greatuser/notes",
+							"description": "my notes",
+							"html_url": "https://codeberg.org/greatuser/notes",
+							"ssh_url": "git@codeberg.org:greatuser/notes.git",
+							"clone_url": "https://codeberg.org/greatuser/notes.git"
+						  }
+						]`,
+					))
+				},
+			).
+			Mock("GET", "/api/v1/orgs/bigorg/repos",
+				func(w http.ResponseWriter, r *http.Request) {
+					// a server whose MAX_RESPONSE_ITEMS is lower than the requested limit
+					page := r.URL.Query().Get("page")
+					w.Header().Set("X-Total-Count", "3")
+					_, _ = w.Write([]byte(`[{"id": ` + page + `, "owner": {"login": "bigorg"}, "name": "repo` + page + `"}]`))
+				},
+			).
+			Mock("GET", "/api/v1/orgs/brokenorg/repos",
+				func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusInternalServerError)
+				},
+			).Client()
+		client, err = upstream.NewGiteaClient(httpClient, "https://codeberg.org")
+		Expect(err).To(BeNil())
+	})
+	It("Returns the matching repositories of an organization", func() {
+		repositories, err = client.GetRepositories(context.Background(), []string{"greatorg"}, false)
+		Expect(err).To(BeNil())
+		Expect(len(repositories)).To(Equal(2))
+		Expect(repositories[0].GetProvider()).To(Equal("codeberg.org"))
+		Expect(repositories[0].GetOwner()).To(Equal("greatorg"))
+		Expect(repositories[0].GetName()).To(Equal("dotfiles"))
+		Expect(repositories[0].GetDescription()).To(Equal("my dotfiles"))
+		Expect(repositories[0].GetBrowserHomepageURL()).To(Equal("https://codeberg.org/greatorg/dotfiles"))
+		Expect(repositories[0].GetBrowserPullRequestsURL()).To(Equal("https://codeberg.org/greatorg/dotfiles/pulls"))
+		Expect(repositories[0].GetOrgURL()).To(Equal("https://codeberg.org/greatorg"))
+		Expect(repositories[0].GetIssuesURL()).To(Equal("https://codeberg.org/greatorg/dotfiles/issues"))
+		Expect(repositories[0].GetCIURL()).To(Equal("https://codeberg.org/greatorg/dotfiles/actions"))
+		Expect(repositories[0].GetReleasesURL()).To(Equal("https://codeberg.org/greatorg/dotfiles/releases"))
+		Expect(repositories[0].GetSettingsURL()).To(Equal("https://codeberg.org/greatorg/dotfiles/settings"))
+		Expect(repositories[0].GetHTTPSCloneURL()).To(Equal("https://codeberg.org/greatorg/dotfiles.git"))
+		Expect(repositories[0].GetSSHCloneURL()).To(Equal("git@codeberg.org:greatorg/dotfiles.git"))
+		Expect(repositories[1].GetName()).To(Equal("personal-website"))
+		Expect(repositories[1].GetDescription()).To(Equal("my personal website"))
+	})
+	It("Falls back to the user repositories if the owner is not an organization", func() {
+		repositories, err = client.GetRepositories(context.Background(), []string{"greatuser"}, false)
+		Expect(err).To(BeNil())
+		Expect(len(repositories)).To(Equal(1))
+		Expect(repositories[0].GetOwner()).To(Equal("greatuser"))
+		Expect(repositories[0].GetName()).To(Equal("notes"))
+	})
+	It("Fetches all pages when the server returns fewer repositories per page than requested", func() {
+		repositories, err = client.GetRepositories(context.Background(), []string{"bigorg"}, false)
+		Expect(err).To(BeNil())
+		Expect(len(repositories)).To(Equal(3))
+		Expect(repositories[2].GetName()).To(Equal("repo3"))
+	})
+	It("Returns the repositories of the other owners when an owner fails", func() {
+		repositories, err = client.GetRepositories(context.Background(), []string{"greatorg", "brokenorg"}, false)
+		var partial *upstream.PartialError
+		Expect(errors.As(err, &partial)).To(BeTrue())
+		Expect(partial.Failed).To(HaveLen(1))
+		Expect(partial.Failed[0].Owner).To(Equal("brokenorg"))
+		Expect(len(repositories)).To(Equal(2))
+
+		results := client.FetchResults()
+		Expect(results).To(HaveLen(2))
+		Expect(results[1].Owner).To(Equal("greatorg"))
+		Expect(results[1].Complete).To(BeTrue())
+	})
+})
@@ -2,27 +2,75 @@ package upstream
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"net/url"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/go-github/github"
 	"golang.org/x/oauth2"
-	"golang.org/x/sync/errgroup"
 )
 
 const pageSize = 100
-const githubUpstream = "github.com"
+const githubHost = "github.com"
+const githubAPIHost = "api.github.com"
 
 type GithubRepository struct {
 	github.Repository
+	Provider string
+	// whether the repository was fetched because the user starred or watches it
+	Starred bool
+	// the visibility reported by the GraphQL API, which unlike the REST API
+	// distinguishes internal repositories
+	Visibility string
+}
+
+func (r *GithubRepository) IsStarred() bool {
+	return r.Starred
+}
+
+// GetMetadata returns the metadata of the repository. The parent of forks is
+// only known if returned by the API, which is not the case when listing
+// repositories.
+func (r *GithubRepository) GetMetadata() Metadata {
+	visibility := r.Visibility
+	switch {
+	case visibility != "":
+	case r.GetPrivate():
+		visibility = "private"
+	default:
+		visibility = "public"
+	}
+
+	id := ""
+	if r.GetID() != 0 {
+		id = strconv.FormatInt(r.GetID(), 10)
+	}
+
+	return Metadata{
+		ID:            id,
+		Stars:         r.GetStargazersCount(),
+		Language:      r.GetLanguage(),
+		DefaultBranch: r.GetDefaultBranch(),
+		Archived:      r.GetArchived(),
+		Fork:          r.GetFork(),
+		Parent:        r.GetParent().GetFullName(),
+		Visibility:    visibility,
+		Topics:        r.Topics,
+		Size:          r.GetSize(),
+		PushedAt:      r.GetPushedAt().Time,
+		UpdatedAt:     r.GetUpdatedAt().Time,
+	}
 }
 
 func (r *GithubRepository) GetProvider() string {
-	return "github"
+	return r.Provider
 }
 
 func (r *GithubRepository) GetName() string {
@@ -83,104 +131,257 @@ func (r *GithubRepository) GetSSHCloneURL() string {
 }
 
 type GithubClient struct {
+	updateTracker
+	fetchResults
+
 	client   *github.Client
+	host     string // the hostname of the GitHub instance e.g. github.com
+	provider string // the provider name of the GitHub instance
 	username string
-}
 
-func NewGithubClient(client *github.Client) *GithubClient {
-	return &GithubClient{client: client, username: "nobody"}
+	// whether to fetch the organizations of the authenticated user in addition
+	// to the configured ones, except for excludeOrgs
+	autoDiscoverOrgs bool
+	excludeOrgs      []string
+
+	// whether to fetch the repositories starred or watched by the
+	// authenticated user
+	fetchStarred bool
+	fetchWatched bool
+
+	// the client of the GraphQL API, if repositories are fetched via GraphQL
+	graphQLClient *http.Client
+	graphQLURL    string
+
+	// the HTTP client and the rate limit transport of the client, if created
+	// via NewGithubClientWithToken
+	httpClient *http.Client
+	rateLimits *RateLimitTransport
 }
 
-func NewGithubClientWithToken(token string) *GithubClient {
-	if token == "" {
-		return &GithubClient{client: github.NewClient(nil), username: "nobody"}
+func NewGithubClient(client *github.Client) *GithubClient {
+	host := client.BaseURL.Hostname()
+	if host == githubAPIHost {
+		host = githubHost
 	}
 
 	return &GithubClient{
-		client: github.NewClient(
-			oauth2.NewClient(
-				context.Background(),
-				oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}),
-			),
-		),
+		client:   client,
+		host:     host,
+		provider: providerName("github", githubHost, host),
 		username: "nobody",
 	}
 }
 
+// NewGithubClientWithToken returns a client for github.com, or for the GitHub
+// Enterprise Server instance at baseURL (e.g. https://github.example.com) if
+// baseURL is not empty. uploadURL defaults to baseURL if empty. Responses are
+// cached in cacheDir unless it is empty.
+func NewGithubClientWithToken(token, baseURL, uploadURL, cacheDir string) (*GithubClient, error) {
+	var transport http.RoundTripper
+	if cacheDir != "" {
+		transport = NewCachingTransport(cacheDir, nil)
+	}
+	rateLimits := NewRateLimitTransport(transport)
+
+	httpClient := &http.Client{Transport: rateLimits}
+	if token != "" {
+		httpClient.Transport = &oauth2.Transport{
+			Source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}),
+			Base:   rateLimits,
+		}
+	}
+
+	client := github.NewClient(httpClient)
+	if baseURL != "" {
+		if uploadURL == "" {
+			uploadURL = baseURL
+		}
+
+		var err error
+		client, err = github.NewEnterpriseClient(
+			enterpriseAPIURL(baseURL, "/api/v3/"),
+			enterpriseAPIURL(uploadURL, "/api/uploads/"),
+			httpClient,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c := NewGithubClient(client)
+	c.httpClient = httpClient
+	c.rateLimits = rateLimits
+	return c, nil
+}
+
+// GetRateLimit returns the API quota reported by the last response
+func (c *GithubClient) GetRateLimit() (RateLimit, bool) {
+	if c.rateLimits == nil {
+		return RateLimit{}, false
+	}
+	return c.rateLimits.RateLimit()
+}
+
+// WithOrgDiscovery makes the client fetch the repositories of all
+// organizations of the authenticated user, except for the excluded ones
+func (c *GithubClient) WithOrgDiscovery(exclude []string) *GithubClient {
+	c.autoDiscoverOrgs = true
+	c.excludeOrgs = exclude
+	return c
+}
+
+// WithStarred makes the client fetch the repositories starred by the
+// authenticated user
+func (c *GithubClient) WithStarred() *GithubClient {
+	c.fetchStarred = true
+	return c
+}
+
+// WithWatched makes the client fetch the repositories watched by the
+// authenticated user
+func (c *GithubClient) WithWatched() *GithubClient {
+	c.fetchWatched = true
+	return c
+}
+
+// enterpriseAPIURL appends apiPath to the URL of a GitHub Enterprise Server
+// instance, unless it already points to an API endpoint
+func enterpriseAPIURL(instanceURL string, apiPath string) string {
+	if strings.Contains(instanceURL, "/api/") {
+		return instanceURL
+	}
+
+	return strings.TrimSuffix(instanceURL, "/") + apiPath
+}
+
+// GetRepositories fetches the repositories of the owners concurrently. When
+// fetching the repositories of some owners fails, the repositories of the
+// other owners are returned along with a PartialError.
 func (c *GithubClient) GetRepositories(ctx context.Context, owners []string, fetchUserRepos bool) ([]HostRepository, error) {
 	res := HostRepositories{}
 	var m sync.Map
 
-	if fetchUserRepos {
-		owners = append(owners, "")
-	}
-
 	if err := c.setUserInfo(ctx); err != nil {
 		return nil, err
 	}
 
-	logAuthenticatedUser(githubUpstream, c.username)
+	logAuthenticatedUser(c.host, c.username)
+	c.reset()
+
+	if c.autoDiscoverOrgs {
+		orgs, err := c.getUserOrgs(ctx)
+		if err != nil {
+			c.record(c.username, false, time.Time{}, nil, fmt.Errorf("discovering organizations: %w", err))
+		}
+		owners = mergeOwners(owners, orgs, c.excludeOrgs)
+	}
+
+	if fetchUserRepos {
+		owners = append(owners, "")
+	}
 
-	var g errgroup.Group
+	var wg sync.WaitGroup
+
+	// the starred repositories are appended after the repositories of the
+	// owners, so that repositories which are also fetched via their owner are
+	// not marked as starred when de-duplicating
+	var starred []HostRepository
+	if c.fetchStarred || c.fetchWatched {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var err error
+			starred, err = c.getStarredRepositories(ctx)
+			c.record(c.username, true, time.Time{}, starred, err)
+		}()
+	}
 
 	for _, owner := range owners {
-		g.Go(func(owner string) func() error {
-			return func() error {
-				var repos []HostRepository
-				var err error
-				repos, err = c.getRepositoriesForOwner(ctx, owner, 0)
-				if err != nil {
-					if err.Error() != "not found" {
-						return err
-					}
-
-				}
-
-				if len(repos) == 0 {
-					repos, err = c.getRepositoriesForOrg(ctx, owner, 0)
-					if err != nil {
-						return err
-					}
-				}
-
-				m.Store(owner, repos)
-				return nil
+		wg.Add(1)
+		go func(owner string) {
+			defer wg.Done()
+			repos, err := c.getRepositoriesOfOwner(ctx, owner)
+			if owner == "" {
+				c.record(c.username, false, c.updatedSince(owner), repos, err)
+			} else {
+				c.record(owner, false, c.updatedSince(owner), repos, err)
+			}
+			if err != nil {
+				return
 			}
-		}(owner))
+
+			c.fetched(owner, repos)
+			m.Store(owner, repos)
+		}(owner)
 	}
 
-	if err := g.Wait(); err != nil {
-		return nil, err
+	wg.Wait()
+
+	// the repositories of the fetched owners are not only starred, even if
+	// they were not fetched via their owner as they were not updated since
+	// the last fetch
+	for _, repo := range starred {
+		if r := repo.(*GithubRepository); ownedBy(r.GetOwner(), owners) || (fetchUserRepos && ownedBy(r.GetOwner(), []string{c.username})) {
+			r.Starred = false
+		}
 	}
 
 	m.Range(func(key, value interface{}) bool {
 		res = append(res, value.([]HostRepository)...)
 		return true
 	})
+	res = append(res, starred...)
 
-	return res.DeDuplicate(), nil
+	return res.DeDuplicate(), c.err()
+}
+
+// getRepositoriesOfOwner fetches the repositories of an organization or a
+// user, or of the authenticated user if owner is empty. Owners are looked up
+// as organizations first, as only the public repositories of organizations are
+// listed with the ones of users.
+func (c *GithubClient) getRepositoriesOfOwner(ctx context.Context, owner string) ([]HostRepository, error) {
+	if c.graphQLClient != nil {
+		return c.getRepositoriesForOwnerGraphQL(ctx, owner)
+	}
+
+	if owner != "" {
+		repos, err := c.getRepositoriesForOrg(ctx, owner, 0)
+		if !errors.Is(err, errNotFound) {
+			return repos, err
+		}
+	}
+
+	return c.getRepositoriesForOwner(ctx, owner, 0)
 }
 
 func (c *GithubClient) getRepositoriesForOwner(ctx context.Context, owner string, startPage int) ([]HostRepository, error) {
 	var reposAcc []*github.Repository
 	opt := &github.RepositoryListOptions{
-		Sort: "updated",
+		Sort:      "updated",
+		Direction: "desc",
 		ListOptions: github.ListOptions{
 			Page:    startPage,
 			PerPage: pageSize,
 		},
 	}
 
+	since := c.updatedSince(owner)
 	for {
-		repos, resp, err := c.client.Repositories.List(ctx, owner, opt)
+		var repos []*github.Repository
+		resp, err := retryRateLimited(ctx, c.host, func() (resp *github.Response, err error) {
+			repos, resp, err = c.client.Repositories.List(ctx, owner, opt)
+			return resp, err
+		})
 		if err != nil {
 			return nil, err
 		}
 
-		logPaginationStatus(githubUpstream, owner, len(repos), resp.LastPage-resp.NextPage, strconv.Itoa(resp.Remaining))
+		logPaginationStatus(c.host, owner, len(repos), resp.LastPage-resp.NextPage, strconv.Itoa(resp.Remaining))
 
+		repos, complete := githubUpdatedAfter(repos, since)
 		reposAcc = append(reposAcc, repos...)
-		if resp.NextPage == 0 {
+		if resp.NextPage == 0 || !complete {
 			break
 		}
 		opt.ListOptions.Page = resp.NextPage
@@ -188,48 +389,168 @@ func (c *GithubClient) getRepositoriesForOwner(ctx context.Context, owner string
 
 	repos := make([]HostRepository, len(reposAcc))
 	for i, r := range reposAcc {
-		repos[i] = &GithubRepository{*r}
+		repos[i] = &GithubRepository{Repository: *r, Provider: c.provider}
 	}
 	return repos, nil
 }
 
+// getRepositoriesForOrg fetches the repositories of org, and returns
+// errNotFound if there is no such organization
 func (c *GithubClient) getRepositoriesForOrg(ctx context.Context, org string, startPage int) ([]HostRepository, error) {
 	var reposAcc []*github.Repository
-	opt := &github.RepositoryListByOrgOptions{
-		ListOptions: github.ListOptions{
-			Page:    startPage,
-			PerPage: pageSize,
-		},
-	}
+	page := startPage
 
+	since := c.updatedSince(org)
 	for {
-		repos, resp, err := c.client.Repositories.ListByOrg(ctx, org, opt)
+		// RepositoryListByOrgOptions lacks the sort parameter of the API,
+		// which is needed to stop at the first repository not updated since
+		req, err := c.client.NewRequest("GET", fmt.Sprintf("orgs/%s/repos?sort=updated&direction=desc&page=%d&per_page=%d", url.PathEscape(org), page, pageSize), nil)
 		if err != nil {
-			if resp.StatusCode != http.StatusNotFound {
-				return nil, err
+			return nil, err
+		}
+
+		var repos []*github.Repository
+		resp, err := retryRateLimited(ctx, c.host, func() (*github.Response, error) {
+			return c.client.Do(ctx, req, &repos)
+		})
+		if err != nil {
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				return nil, errNotFound
 			}
-			return []HostRepository{}, nil
+			return nil, err
 		}
 
-		logPaginationStatus(githubUpstream, org, len(repos), resp.LastPage-resp.NextPage, strconv.Itoa(resp.Remaining))
+		logPaginationStatus(c.host, org, len(repos), resp.LastPage-resp.NextPage, strconv.Itoa(resp.Remaining))
 
+		repos, complete := githubUpdatedAfter(repos, since)
 		reposAcc = append(reposAcc, repos...)
-		if resp.NextPage == 0 {
+		if resp.NextPage == 0 || !complete {
 			break
 		}
-		opt.ListOptions.Page = resp.NextPage
+		page = resp.NextPage
 	}
 
 	repos := make([]HostRepository, len(reposAcc))
 	for i, r := range reposAcc {
-		repos[i] = &GithubRepository{*r}
+		repos[i] = &GithubRepository{Repository: *r, Provider: c.provider}
 	}
 	return repos, nil
 }
 
+// githubUpdatedAfter returns the repositories of a page sorted by most recent
+// update which were updated after since, and whether all of them were, i.e.
+// whether the next page may contain repositories updated after since
+func githubUpdatedAfter(repos []*github.Repository, since time.Time) ([]*github.Repository, bool) {
+	if since.IsZero() {
+		return repos, true
+	}
+
+	for i, r := range repos {
+		if !r.GetUpdatedAt().Time.After(since) {
+			return repos[:i], false
+		}
+	}
+
+	return repos, true
+}
+
+// getStarredRepositories fetches the repositories starred and/or watched by
+// the authenticated user, depending on the enabled options
+func (c *GithubClient) getStarredRepositories(ctx context.Context) ([]HostRepository, error) {
+	if c.graphQLClient != nil {
+		return c.getStarredRepositoriesGraphQL(ctx)
+	}
+
+	var reposAcc []*github.Repository
+
+	if c.fetchStarred {
+		opt := &github.ActivityListStarredOptions{ListOptions: github.ListOptions{PerPage: pageSize}}
+		for {
+			var starred []*github.StarredRepository
+			resp, err := retryRateLimited(ctx, c.host, func() (resp *github.Response, err error) {
+				starred, resp, err = c.client.Activity.ListStarred(ctx, "", opt)
+				return resp, err
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			logPaginationStatus(c.host, "starred", len(starred), resp.LastPage-resp.NextPage, strconv.Itoa(resp.Remaining))
+
+			for _, s := range starred {
+				reposAcc = append(reposAcc, s.GetRepository())
+			}
+			if resp.NextPage == 0 {
+				break
+			}
+			opt.ListOptions.Page = resp.NextPage
+		}
+	}
+
+	if c.fetchWatched {
+		opt := &github.ListOptions{PerPage: pageSize}
+		for {
+			var watched []*github.Repository
+			resp, err := retryRateLimited(ctx, c.host, func() (resp *github.Response, err error) {
+				watched, resp, err = c.client.Activity.ListWatched(ctx, "", opt)
+				return resp, err
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			logPaginationStatus(c.host, "watched", len(watched), resp.LastPage-resp.NextPage, strconv.Itoa(resp.Remaining))
+
+			reposAcc = append(reposAcc, watched...)
+			if resp.NextPage == 0 {
+				break
+			}
+			opt.Page = resp.NextPage
+		}
+	}
+
+	repos := make([]HostRepository, len(reposAcc))
+	for i, r := range reposAcc {
+		repos[i] = &GithubRepository{Repository: *r, Provider: c.provider, Starred: true}
+	}
+	return repos, nil
+}
+
+// getUserOrgs fetches the logins of the organizations of the authenticated
+// user
+func (c *GithubClient) getUserOrgs(ctx context.Context) ([]string, error) {
+	var orgs []string
+	opt := &github.ListOptions{PerPage: pageSize}
+	for {
+		var page []*github.Organization
+		resp, err := retryRateLimited(ctx, c.host, func() (resp *github.Response, err error) {
+			page, resp, err = c.client.Organizations.List(ctx, "", opt)
+			return resp, err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, org := range page {
+			orgs = append(orgs, org.GetLogin())
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	log.Printf("Discovered %d organizations on %s", len(orgs), c.host)
+	return orgs, nil
+}
+
 // setUserInfo fetches the authenticated user's information and stores it
 func (c *GithubClient) setUserInfo(ctx context.Context) error {
-	user, _, err := c.client.Users.Get(ctx, "")
+	var user *github.User
+	_, err := retryRateLimited(ctx, c.host, func() (resp *github.Response, err error) {
+		user, resp, err = c.client.Users.Get(ctx, "")
+		return resp, err
+	})
 	if err != nil {
 		log.Println("Unable to get user information, perhaps a github token is not set?")
 		return err
@@ -238,3 +559,30 @@ func (c *GithubClient) setUserInfo(ctx context.Context) error {
 	c.username = user.GetLogin()
 	return nil
 }
+
+// retryRateLimited calls do, and calls it again once the rate limit resets if
+// it fails with a RateLimitError. go-github returns the error without sending
+// the request when the last response exhausted the quota, so RateLimitTransport
+// never gets to wait for it.
+func retryRateLimited(ctx context.Context, host string, do func() (*github.Response, error)) (*github.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := do()
+
+		var rateLimitErr *github.RateLimitError
+		if !errors.As(err, &rateLimitErr) || attempt == rateLimitMaxRetries {
+			return resp, err
+		}
+
+		wait := time.Until(rateLimitErr.Rate.Reset.Time)
+		if wait < 0 {
+			wait = 0
+		}
+
+		log.Printf("[%s] rate limited, waiting %s until %s", host, wait.Round(time.Second), time.Now().Add(wait).Format(time.Kitchen))
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return resp, ctx.Err()
+		}
+	}
+}
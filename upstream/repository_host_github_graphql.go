@@ -0,0 +1,288 @@
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// githubGraphQLRepositoriesFields are the fields of a page of repositories
+// queried from the GraphQL API of GitHub, limited to the fields stored by ogit
+const githubGraphQLRepositoriesFields = `
+	totalCount
+	pageInfo { hasNextPage endCursor }
+	nodes {
+		databaseId
+		name
+		owner { login }
+		description
+		url
+		sshUrl
+		isPrivate
+		visibility
+		stargazerCount
+		primaryLanguage { name }
+		defaultBranchRef { name }
+		isArchived
+		isFork
+		parent { nameWithOwner }
+		repositoryTopics(first: 20) { nodes { topic { name } } }
+		diskUsage
+		pushedAt
+		updatedAt
+	}`
+
+// githubOwnerQuery fetches the repositories of a user or an organization
+const githubOwnerQuery = `query($login: String!, $cursor: String) {
+	rateLimit { remaining }
+	owner: repositoryOwner(login: $login) {
+		repositories(first: 100, after: $cursor, orderBy: {field: UPDATED_AT, direction: DESC}) {` + githubGraphQLRepositoriesFields + `
+		}
+	}
+}`
+
+// githubViewerQuery fetches the repositories of the authenticated user,
+// including the repositories of its organizations and the ones it collaborates
+// on, as the REST API does
+const githubViewerQuery = `query($cursor: String) {
+	rateLimit { remaining }
+	owner: viewer {
+		repositories(first: 100, after: $cursor, orderBy: {field: UPDATED_AT, direction: DESC}, affiliations: [OWNER, COLLABORATOR, ORGANIZATION_MEMBER], ownerAffiliations: [OWNER, COLLABORATOR, ORGANIZATION_MEMBER]) {` + githubGraphQLRepositoriesFields + `
+		}
+	}
+}`
+
+const githubStarredQuery = `query($cursor: String) {
+	rateLimit { remaining }
+	owner: viewer {
+		repositories: starredRepositories(first: 100, after: $cursor) {` + githubGraphQLRepositoriesFields + `
+		}
+	}
+}`
+
+const githubWatchedQuery = `query($cursor: String) {
+	rateLimit { remaining }
+	owner: viewer {
+		repositories: watching(first: 100, after: $cursor) {` + githubGraphQLRepositoriesFields + `
+		}
+	}
+}`
+
+// githubGraphQLRepository is a repository as returned by the GraphQL API of
+// GitHub
+type githubGraphQLRepository struct {
+	DatabaseID int64  `json:"databaseId"`
+	Name       string `json:"name"`
+	Owner      struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+	Description     string `json:"description"`
+	URL             string `json:"url"`
+	SSHURL          string `json:"sshUrl"`
+	IsPrivate       bool   `json:"isPrivate"`
+	Visibility      string `json:"visibility"`
+	StargazerCount  int    `json:"stargazerCount"`
+	PrimaryLanguage *struct {
+		Name string `json:"name"`
+	} `json:"primaryLanguage"`
+	DefaultBranchRef *struct {
+		Name string `json:"name"`
+	} `json:"defaultBranchRef"`
+	IsArchived bool `json:"isArchived"`
+	IsFork     bool `json:"isFork"`
+	Parent     *struct {
+		NameWithOwner string `json:"nameWithOwner"`
+	} `json:"parent"`
+	RepositoryTopics struct {
+		Nodes []struct {
+			Topic struct {
+				Name string `json:"name"`
+			} `json:"topic"`
+		} `json:"nodes"`
+	} `json:"repositoryTopics"`
+	DiskUsage int        `json:"diskUsage"`
+	PushedAt  *time.Time `json:"pushedAt"`
+	UpdatedAt *time.Time `json:"updatedAt"`
+}
+
+// toGithubRepository converts r to the repository type returned by the REST
+// API, so that repositories are handled the same regardless of the API
+func (r *githubGraphQLRepository) toGithubRepository(provider string, starred bool) *GithubRepository {
+	repo := github.Repository{
+		ID:              github.Int64(r.DatabaseID),
+		Name:            github.String(r.Name),
+		FullName:        github.String(r.Owner.Login + "/" + r.Name),
+		Owner:           &github.User{Login: github.String(r.Owner.Login)},
+		Description:     github.String(r.Description),
+		HTMLURL:         github.String(r.URL),
+		SSHURL:          github.String(r.SSHURL),
+		Private:         github.Bool(r.IsPrivate),
+		StargazersCount: github.Int(r.StargazerCount),
+		Archived:        github.Bool(r.IsArchived),
+		Fork:            github.Bool(r.IsFork),
+		Size:            github.Int(r.DiskUsage),
+	}
+
+	if r.PrimaryLanguage != nil {
+		repo.Language = github.String(r.PrimaryLanguage.Name)
+	}
+	if r.DefaultBranchRef != nil {
+		repo.DefaultBranch = github.String(r.DefaultBranchRef.Name)
+	}
+	if r.Parent != nil {
+		repo.Parent = &github.Repository{FullName: github.String(r.Parent.NameWithOwner)}
+	}
+	for _, node := range r.RepositoryTopics.Nodes {
+		repo.Topics = append(repo.Topics, node.Topic.Name)
+	}
+	if r.PushedAt != nil {
+		repo.PushedAt = &github.Timestamp{Time: *r.PushedAt}
+	}
+	if r.UpdatedAt != nil {
+		repo.UpdatedAt = &github.Timestamp{Time: *r.UpdatedAt}
+	}
+
+	return &GithubRepository{
+		Repository: repo,
+		Provider:   provider,
+		Starred:    starred,
+		Visibility: strings.ToLower(r.Visibility),
+	}
+}
+
+// githubGraphQLResponse is the response of the repository queries
+type githubGraphQLResponse struct {
+	Data struct {
+		RateLimit struct {
+			Remaining int `json:"remaining"`
+		} `json:"rateLimit"`
+		Owner *struct {
+			Repositories struct {
+				TotalCount int `json:"totalCount"`
+				PageInfo   struct {
+					HasNextPage bool   `json:"hasNextPage"`
+					EndCursor   string `json:"endCursor"`
+				} `json:"pageInfo"`
+				Nodes []*githubGraphQLRepository `json:"nodes"`
+			} `json:"repositories"`
+		} `json:"owner"`
+	} `json:"data"`
+	Errors []struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// WithGraphQL makes the client fetch repositories via the GraphQL API of
+// GitHub using client, which requires authentication, instead of the REST API
+func (c *GithubClient) WithGraphQL(client *http.Client) *GithubClient {
+	c.graphQLClient = client
+	c.graphQLURL = githubGraphQLURL(c.client.BaseURL.String())
+	return c
+}
+
+// githubGraphQLURL returns the GraphQL endpoint of the instance whose REST API
+// is at apiURL e.g. https://api.github.com/graphql or
+// https://github.example.com/api/graphql
+func githubGraphQLURL(apiURL string) string {
+	if strings.HasSuffix(apiURL, "/api/v3/") {
+		return strings.TrimSuffix(apiURL, "v3/") + "graphql"
+	}
+
+	return strings.TrimSuffix(apiURL, "/") + "/graphql"
+}
+
+// getRepositoriesForOwnerGraphQL fetches the repositories of a user or an
+// organization, or of the authenticated user if owner is empty
+func (c *GithubClient) getRepositoriesForOwnerGraphQL(ctx context.Context, owner string) ([]HostRepository, error) {
+	if owner == "" {
+		return c.getRepositoriesGraphQL(ctx, githubViewerQuery, c.username, c.updatedSince(owner), false)
+	}
+
+	return c.getRepositoriesGraphQL(ctx, githubOwnerQuery, owner, c.updatedSince(owner), false)
+}
+
+// getStarredRepositoriesGraphQL fetches the repositories starred and/or
+// watched by the authenticated user, depending on the enabled options
+func (c *GithubClient) getStarredRepositoriesGraphQL(ctx context.Context) ([]HostRepository, error) {
+	repos := []HostRepository{}
+
+	if c.fetchStarred {
+		starred, err := c.getRepositoriesGraphQL(ctx, githubStarredQuery, "starred", time.Time{}, true)
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, starred...)
+	}
+
+	if c.fetchWatched {
+		watched, err := c.getRepositoriesGraphQL(ctx, githubWatchedQuery, "watched", time.Time{}, true)
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, watched...)
+	}
+
+	return repos, nil
+}
+
+// getRepositoriesGraphQL fetches all pages of the repositories returned by
+// query. login is the owner of the repositories for githubOwnerQuery, and is
+// only used for logging otherwise. Unless since is zero, fetching stops at the
+// first repository not updated after since, as the repositories of owners are
+// sorted by most recent update.
+func (c *GithubClient) getRepositoriesGraphQL(ctx context.Context, query string, login string, since time.Time, starred bool) ([]HostRepository, error) {
+	repos := []HostRepository{}
+	var cursor *string
+	for {
+		variables := map[string]interface{}{"cursor": cursor}
+		if query == githubOwnerQuery {
+			variables["login"] = login
+		}
+
+		var resp githubGraphQLResponse
+		if err := postJSON(ctx, c.graphQLClient, c.graphQLURL, map[string]interface{}{
+			"query":     query,
+			"variables": variables,
+		}, &resp); err != nil {
+			return nil, err
+		}
+
+		for _, e := range resp.Errors {
+			if e.Type != "NOT_FOUND" {
+				return nil, fmt.Errorf("github graphql: %s", e.Message)
+			}
+		}
+
+		owner := resp.Data.Owner
+		if owner == nil {
+			// the owner does not exist, which fails as with the REST API
+			// rather than returning no repositories
+			return nil, fmt.Errorf("github graphql: owner %s %w", login, errNotFound)
+		}
+
+		complete := true
+		for _, r := range owner.Repositories.Nodes {
+			if !since.IsZero() && (r.UpdatedAt == nil || !r.UpdatedAt.After(since)) {
+				complete = false
+				break
+			}
+			repos = append(repos, r.toGithubRepository(c.provider, starred))
+		}
+
+		remainingPages := (owner.Repositories.TotalCount - len(repos) + pageSize - 1) / pageSize
+		logPaginationStatus(c.host, login, len(owner.Repositories.Nodes), remainingPages, strconv.Itoa(resp.Data.RateLimit.Remaining))
+
+		if !owner.Repositories.PageInfo.HasNextPage || !complete {
+			break
+		}
+		cursor = &owner.Repositories.PageInfo.EndCursor
+	}
+
+	return repos, nil
+}
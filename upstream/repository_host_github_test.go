@@ -2,7 +2,12 @@ package upstream_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/go-github/github"
 	. "github.com/onsi/ginkgo"
@@ -71,6 +76,11 @@ var _ = Describe("Github repo", func() {
 					`))
 				},
 			).
+			Mock("GET", "/orgs/greatuser/repos",
+				func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusNotFound)
+				},
+			).
 			Mock("GET", "/users/greatuser/repos",
 				func(w http.ResponseWriter, r *http.Request) {
 					_, _ = w.Write([]byte(`[
@@ -105,3 +115,510 @@ var _ = Describe("Github repo", func() {
 		Expect(repositories[1].GetProvider()).To(Equal("github"))
 	})
 })
+
+var _ = Describe("Github Enterprise Server repo", func() {
+	var client *upstream.GithubClient
+	var repositories []upstream.HostRepository
+	BeforeEach(func() {
+		httpClient := mock.NewHTTPClient().
+			Mock("GET", "/api/v3/user",
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`{"login": "octocat", "id": 1}`))
+				},
+			).
+			Mock("GET", "/api/v3/orgs/greatorg/repos",
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`[
+						{
+							"id": 1296269,
+							"name": "dotfiles",
+							"full_name": "greatorg/dotfiles",
+							"private": true,
+							"html_url": "https://github.example.com/greatorg/dotfiles",
+							"ssh_url": "git@github.example.com:greatorg/dotfiles.git",
+							"owner": {
+								"login": "greatorg"
+							},
+							"stargazers_count": 42,
+							"language": "Shell",
+							"default_branch": "main",
+							"fork": true,
+							"topics": ["dotfiles", "zsh"],
+							"size": 108,
+							"pushed_at": "2021-11-02T10:00:00Z"
+						}
+					]`))
+				},
+			).Client()
+		githubClient, err := github.NewEnterpriseClient(
+			"https://github.example.com/api/v3/",
+			"https://github.example.com/api/uploads/",
+			httpClient,
+		)
+		Expect(err).To(BeNil())
+		client = upstream.NewGithubClient(githubClient)
+		repositories, err = client.GetRepositories(context.Background(), []string{"greatorg"}, false)
+		Expect(err).To(BeNil())
+	})
+	It("Returns the matching repositories with the instance hostname as provider", func() {
+		Expect(len(repositories)).To(Equal(1))
+		Expect(repositories[0].GetProvider()).To(Equal("github.example.com"))
+		Expect(repositories[0].GetOwner()).To(Equal("greatorg"))
+		Expect(repositories[0].GetName()).To(Equal("dotfiles"))
+		Expect(repositories[0].GetBrowserHomepageURL()).To(Equal("https://github.example.com/greatorg/dotfiles"))
+		Expect(repositories[0].GetBrowserPullRequestsURL()).To(Equal("https://github.example.com/greatorg/dotfiles/pulls"))
+		Expect(repositories[0].GetOrgURL()).To(Equal("https://github.example.com/greatorg"))
+		Expect(repositories[0].GetIssuesURL()).To(Equal("https://github.example.com/greatorg/dotfiles/issues"))
+		Expect(repositories[0].GetCIURL()).To(Equal("https://github.example.com/greatorg/dotfiles/actions"))
+		Expect(repositories[0].GetReleasesURL()).To(Equal("https://github.example.com/greatorg/dotfiles/releases"))
+		Expect(repositories[0].GetSettingsURL()).To(Equal("https://github.example.com/greatorg/dotfiles/settings"))
+		Expect(repositories[0].GetHTTPSCloneURL()).To(Equal("https://github.example.com/greatorg/dotfiles"))
+		Expect(repositories[0].GetSSHCloneURL()).To(Equal("git@github.example.com:greatorg/dotfiles.git"))
+	})
+	It("Returns the metadata of the repositories", func() {
+		metadata := repositories[0].(upstream.MetadataReporter).GetMetadata()
+		Expect(metadata.ID).To(Equal("1296269"))
+		Expect(metadata.Stars).To(Equal(42))
+		Expect(metadata.Language).To(Equal("Shell"))
+		Expect(metadata.DefaultBranch).To(Equal("main"))
+		Expect(metadata.Archived).To(BeFalse())
+		Expect(metadata.Fork).To(BeTrue())
+		Expect(metadata.Visibility).To(Equal("private"))
+		Expect(metadata.Topics).To(Equal([]string{"dotfiles", "zsh"}))
+		Expect(metadata.Size).To(Equal(108))
+		Expect(metadata.PushedAt).To(Equal(time.Date(2021, 11, 2, 10, 0, 0, 0, time.UTC)))
+	})
+})
+
+var _ = Describe("Github repo with organization discovery", func() {
+	var repositories []upstream.HostRepository
+	var err error
+	BeforeEach(func() {
+		repoHandler := func(owner string) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(`[
+					{
+						"name": "dotfiles",
+						"full_name": "` + owner + `/dotfiles",
+						"owner": {"login": "` + owner + `"}
+					}
+				]`))
+			}
+		}
+		httpClient := mock.NewHTTPClient().
+			Mock("GET", "/user",
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`{"login": "octocat", "id": 1}`))
+				},
+			).
+			Mock("GET", "/user/orgs",
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`[{"login": "discoveredorg"}, {"login": "NoisyOrg"}, {"login": "greatorg"}]`))
+				},
+			).
+			Mock("GET", "/orgs/greatorg/repos", repoHandler("greatorg")).
+			Mock("GET", "/orgs/discoveredorg/repos", repoHandler("discoveredorg")).
+			Client()
+		client := upstream.NewGithubClient(github.NewClient(httpClient)).WithOrgDiscovery([]string{"noisyorg"})
+		repositories, err = client.GetRepositories(context.Background(), []string{"greatorg"}, false)
+		Expect(err).To(BeNil())
+	})
+	It("Returns the repositories of the configured and discovered organizations except the excluded ones", func() {
+		Expect(len(repositories)).To(Equal(2))
+		owners := []string{repositories[0].GetOwner(), repositories[1].GetOwner()}
+		Expect(owners).To(ConsistOf("greatorg", "discoveredorg"))
+	})
+})
+
+var _ = Describe("Github repo with starred repositories", func() {
+	var repositories []upstream.HostRepository
+	var err error
+	BeforeEach(func() {
+		httpClient := mock.NewHTTPClient().
+			Mock("GET", "/user",
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`{"login": "octocat", "id": 1}`))
+				},
+			).
+			Mock("GET", "/orgs/greatorg/repos",
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`[{"name": "dotfiles", "owner": {"login": "greatorg"}}]`))
+				},
+			).
+			Mock("GET", "/user/starred",
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`[
+						{"starred_at": "2022-01-01T00:00:00Z", "repo": {"name": "dotfiles", "owner": {"login": "greatorg"}}},
+						{"starred_at": "2022-01-01T00:00:00Z", "repo": {"name": "bubbletea", "owner": {"login": "charmbracelet"}}}
+					]`))
+				},
+			).
+			Mock("GET", "/user/subscriptions",
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`[{"name": "vim-fugitive", "owner": {"login": "tpope"}}]`))
+				},
+			).
+			Client()
+		client := upstream.NewGithubClient(github.NewClient(httpClient)).WithStarred().WithWatched()
+		repositories, err = client.GetRepositories(context.Background(), []string{"greatorg"}, false)
+		Expect(err).To(BeNil())
+	})
+	It("Marks the repositories fetched only because they are starred or watched", func() {
+		Expect(len(repositories)).To(Equal(3))
+		Expect(repositories[0].GetName()).To(Equal("dotfiles"))
+		Expect(repositories[0].(upstream.StarredReporter).IsStarred()).To(BeFalse())
+		Expect(repositories[1].GetOwner()).To(Equal("charmbracelet"))
+		Expect(repositories[1].GetName()).To(Equal("bubbletea"))
+		Expect(repositories[1].(upstream.StarredReporter).IsStarred()).To(BeTrue())
+		Expect(repositories[2].GetName()).To(Equal("vim-fugitive"))
+		Expect(repositories[2].(upstream.StarredReporter).IsStarred()).To(BeTrue())
+	})
+})
+
+var _ = Describe("Github repo with a failing organization", func() {
+	var client *upstream.GithubClient
+	var repositories []upstream.HostRepository
+	var err error
+	BeforeEach(func() {
+		httpClient := mock.NewHTTPClient().
+			Mock("GET", "/user",
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`{"login": "octocat", "id": 1}`))
+				},
+			).
+			Mock("GET", "/orgs/greatorg/repos",
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`[{"name": "dotfiles", "owner": {"login": "greatorg"}}]`))
+				},
+			).
+			Mock("GET", "/orgs/brokenorg/repos",
+				func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusInternalServerError)
+				},
+			).
+			Client()
+		client = upstream.NewGithubClient(github.NewClient(httpClient))
+		repositories, err = client.GetRepositories(context.Background(), []string{"greatorg", "brokenorg"}, false)
+	})
+	It("Returns the repositories of the other organizations along with the failure", func() {
+		var partial *upstream.PartialError
+		Expect(errors.As(err, &partial)).To(BeTrue())
+		Expect(partial.Failed).To(HaveLen(1))
+		Expect(partial.Failed[0].Owner).To(Equal("brokenorg"))
+		Expect(len(repositories)).To(Equal(1))
+		Expect(repositories[0].GetName()).To(Equal("dotfiles"))
+	})
+	It("Reports the number of repositories fetched for each organization", func() {
+		results := client.FetchResults()
+		Expect(results).To(HaveLen(2))
+		Expect(results[0].Owner).To(Equal("brokenorg"))
+		Expect(results[0].Err).NotTo(BeNil())
+		Expect(results[1].Owner).To(Equal("greatorg"))
+		Expect(results[1].Fetched).To(Equal(1))
+		Expect(results[1].Err).To(BeNil())
+	})
+})
+
+// failingTransport fails the requests whose path starts with prefix, and sends
+// the others via base
+type failingTransport struct {
+	base   http.RoundTripper
+	prefix string
+}
+
+func (t failingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if strings.HasPrefix(r.URL.Path, t.prefix) {
+		return nil, errors.New("connection reset by peer")
+	}
+	return t.base.RoundTrip(r)
+}
+
+var _ = Describe("Github repo with an unreachable organization", func() {
+	var repositories []upstream.HostRepository
+	var err error
+	BeforeEach(func() {
+		httpClient := mock.NewHTTPClient().
+			Mock("GET", "/user",
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`{"login": "octocat", "id": 1}`))
+				},
+			).
+			Client()
+		httpClient.Transport = failingTransport{base: httpClient.Transport, prefix: "/orgs/"}
+		client := upstream.NewGithubClient(github.NewClient(httpClient))
+		repositories, err = client.GetRepositories(context.Background(), []string{"greatorg"}, false)
+	})
+	It("Returns the transport error of the organization", func() {
+		var partial *upstream.PartialError
+		Expect(errors.As(err, &partial)).To(BeTrue())
+		Expect(partial.Failed).To(HaveLen(1))
+		Expect(partial.Failed[0].Owner).To(Equal("greatorg"))
+		Expect(partial.Failed[0].Err).To(MatchError(ContainSubstring("connection reset by peer")))
+		Expect(repositories).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Github repo with an exhausted rate limit", func() {
+	var repositories []upstream.HostRepository
+	var err error
+	BeforeEach(func() {
+		httpClient := mock.NewHTTPClient().
+			Mock("GET", "/user",
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`{"login": "octocat", "id": 1}`))
+				},
+			).
+			Mock("GET", "/orgs/greatorg/repos",
+				func(w http.ResponseWriter, r *http.Request) {
+					if r.URL.Query().Get("page") == "2" {
+						_, _ = w.Write([]byte(`[{"name": "personal-website", "owner": {"login": "greatorg"}}]`))
+						return
+					}
+
+					// go-github refuses to send the next request until the reset
+					w.Header().Set("X-RateLimit-Limit", "5000")
+					w.Header().Set("X-RateLimit-Remaining", "0")
+					w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(2*time.Second).Unix(), 10))
+					w.Header().Set("Link", `<https://api.github.com/users/greatorg/repos?page=2>; rel="next", <https://api.github.com/users/greatorg/repos?page=2>; rel="last"`)
+					_, _ = w.Write([]byte(`[{"name": "dotfiles", "owner": {"login": "greatorg"}}]`))
+				},
+			).
+			Client()
+		client := upstream.NewGithubClient(github.NewClient(httpClient))
+		repositories, err = client.GetRepositories(context.Background(), []string{"greatorg"}, false)
+	})
+	It("Waits until the rate limit resets and fetches the next page", func() {
+		Expect(err).To(BeNil())
+		Expect(len(repositories)).To(Equal(2))
+	})
+})
+
+var _ = Describe("Github repo fetched incrementally", func() {
+	var client *upstream.GithubClient
+	var repositories []upstream.HostRepository
+	var query string
+	BeforeEach(func() {
+		httpClient := mock.NewHTTPClient().
+			Mock("GET", "/user",
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`{"login": "octocat", "id": 1}`))
+				},
+			).
+			Mock("GET", "/orgs/greatorg/repos",
+				func(w http.ResponseWriter, r *http.Request) {
+					query = r.URL.RawQuery
+					w.Header().Set("Link", `<https://api.github.com/users/greatorg/repos?page=2>; rel="next", <https://api.github.com/users/greatorg/repos?page=2>; rel="last"`)
+					_, _ = w.Write([]byte(`[
+						{"name": "dotfiles", "owner": {"login": "greatorg"}, "updated_at": "2021-11-03T10:00:00Z"},
+						{"name": "personal-website", "owner": {"login": "greatorg"}, "updated_at": "2021-10-01T10:00:00Z"}
+					]`))
+				},
+			).
+			Client()
+		client = upstream.NewGithubClient(github.NewClient(httpClient))
+		client.SetUpdatedSince(map[string]time.Time{"greatorg": time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC)})
+
+		var err error
+		repositories, err = client.GetRepositories(context.Background(), []string{"greatorg"}, false)
+		Expect(err).To(BeNil())
+	})
+	It("Returns only the repositories updated since the last fetch, sorted by most recent update", func() {
+		Expect(query).To(ContainSubstring("sort=updated"))
+		Expect(query).To(ContainSubstring("direction=desc"))
+		Expect(len(repositories)).To(Equal(1))
+		Expect(repositories[0].GetName()).To(Equal("dotfiles"))
+	})
+	It("Reports the most recent update of the fetched repositories", func() {
+		Expect(client.UpdatedUntil()).To(Equal(map[string]time.Time{
+			"greatorg": time.Date(2021, 11, 3, 10, 0, 0, 0, time.UTC),
+		}))
+	})
+})
+
+var _ = Describe("Github repo fetched incrementally with starred repositories", func() {
+	var repositories []upstream.HostRepository
+	var userRequests int
+	BeforeEach(func() {
+		userRequests = 0
+		httpClient := mock.NewHTTPClient().
+			Mock("GET", "/user",
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`{"login": "octocat", "id": 1}`))
+				},
+			).
+			Mock("GET", "/orgs/greatorg/repos",
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`[{"name": "dotfiles", "owner": {"login": "greatorg"}, "updated_at": "2021-10-01T10:00:00Z"}]`))
+				},
+			).
+			Mock("GET", "/users/greatorg/repos",
+				func(w http.ResponseWriter, r *http.Request) {
+					userRequests++
+					_, _ = w.Write([]byte(`[]`))
+				},
+			).
+			Mock("GET", "/user/starred",
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`[{"starred_at": "2022-01-01T00:00:00Z", "repo": {"name": "dotfiles", "owner": {"login": "greatorg"}}}]`))
+				},
+			).
+			Client()
+		client := upstream.NewGithubClient(github.NewClient(httpClient)).WithStarred()
+		client.SetUpdatedSince(map[string]time.Time{"greatorg": time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC)})
+
+		var err error
+		repositories, err = client.GetRepositories(context.Background(), []string{"greatorg"}, false)
+		Expect(err).To(BeNil())
+	})
+	It("Does not look the organization up as a user when none of its repositories was updated", func() {
+		Expect(userRequests).To(Equal(0))
+	})
+	It("Does not mark the starred repositories of the organization as only starred", func() {
+		Expect(len(repositories)).To(Equal(1))
+		Expect(repositories[0].GetName()).To(Equal("dotfiles"))
+		Expect(repositories[0].(upstream.StarredReporter).IsStarred()).To(BeFalse())
+	})
+})
+
+var _ = Describe("Github repo via GraphQL", func() {
+	var repositories []upstream.HostRepository
+	var logins []string
+	var cursors []interface{}
+	BeforeEach(func() {
+		logins, cursors = nil, nil
+		httpClient := mock.NewHTTPClient().
+			Mock("GET", "/user",
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`{"login": "octocat", "id": 1}`))
+				},
+			).
+			Mock("POST", "/graphql",
+				func(w http.ResponseWriter, r *http.Request) {
+					var req struct {
+						Query     string                 `json:"query"`
+						Variables map[string]interface{} `json:"variables"`
+					}
+					_ = json.NewDecoder(r.Body).Decode(&req)
+					logins = append(logins, req.Variables["login"].(string))
+					cursors = append(cursors, req.Variables["cursor"])
+
+					if req.Variables["cursor"] == nil {
+						_, _ = w.Write([]byte(`{"data": {
+							"rateLimit": {"remaining": 4999},
+							"owner": {"repositories": {
+								"totalCount": 2,
+								"pageInfo": {"hasNextPage": true, "endCursor": "Y3Vyc29yOjE="},
+								"nodes": [{
+									"databaseId": 1296269,
+									"name": "dotfiles",
+									"owner": {"login": "greatorg"},
+									"description": "my dotfiles",
+									"url": "https://github.com/greatorg/dotfiles",
+									"sshUrl": "git@github.com:greatorg/dotfiles.git",
+									"isPrivate": true,
+									"visibility": "INTERNAL",
+									"stargazerCount": 42,
+									"primaryLanguage": {"name": "Shell"},
+									"defaultBranchRef": {"name": "main"},
+									"isArchived": false,
+									"isFork": true,
+									"parent": {"nameWithOwner": "thoughtbot/dotfiles"},
+									"repositoryTopics": {"nodes": [{"topic": {"name": "dotfiles"}}, {"topic": {"name": "zsh"}}]},
+									"diskUsage": 108,
+									"pushedAt": "2021-11-02T10:00:00Z",
+									"updatedAt": "2021-11-03T10:00:00Z"
+								}]
+							}}
+						}}`))
+						return
+					}
+
+					_, _ = w.Write([]byte(`{"data": {
+						"rateLimit": {"remaining": 4998},
+						"owner": {"repositories": {
+							"totalCount": 2,
+							"pageInfo": {"hasNextPage": false, "endCursor": "Y3Vyc29yOjI="},
+							"nodes": [{
+								"name": "personal-website",
+								"owner": {"login": "greatorg"},
+								"url": "https://github.com/greatorg/personal-website",
+								"sshUrl": "git@github.com:greatorg/personal-website.git",
+								"visibility": "PUBLIC",
+								"defaultBranchRef": null,
+								"primaryLanguage": null,
+								"parent": null,
+								"repositoryTopics": {"nodes": []}
+							}]
+						}}
+					}}`))
+				},
+			).Client()
+		client := upstream.NewGithubClient(github.NewClient(httpClient)).WithGraphQL(httpClient)
+
+		var err error
+		repositories, err = client.GetRepositories(context.Background(), []string{"greatorg"}, false)
+		Expect(err).To(BeNil())
+	})
+	It("Fetches all pages of the repositories with a cursor", func() {
+		Expect(logins).To(Equal([]string{"greatorg", "greatorg"}))
+		Expect(cursors).To(Equal([]interface{}{nil, "Y3Vyc29yOjE="}))
+		Expect(len(repositories)).To(Equal(2))
+		Expect(repositories[0].GetName()).To(Equal("dotfiles"))
+		Expect(repositories[1].GetName()).To(Equal("personal-website"))
+	})
+	It("Returns the URLs and metadata of the repositories", func() {
+		Expect(repositories[0].GetProvider()).To(Equal("github"))
+		Expect(repositories[0].GetOwner()).To(Equal("greatorg"))
+		Expect(repositories[0].GetDescription()).To(Equal("my dotfiles"))
+		Expect(repositories[0].GetBrowserPullRequestsURL()).To(Equal("https://github.com/greatorg/dotfiles/pulls"))
+		Expect(repositories[0].GetSSHCloneURL()).To(Equal("git@github.com:greatorg/dotfiles.git"))
+
+		metadata := repositories[0].(upstream.MetadataReporter).GetMetadata()
+		Expect(metadata.ID).To(Equal("1296269"))
+		Expect(metadata.Stars).To(Equal(42))
+		Expect(metadata.Language).To(Equal("Shell"))
+		Expect(metadata.DefaultBranch).To(Equal("main"))
+		Expect(metadata.Fork).To(BeTrue())
+		Expect(metadata.Parent).To(Equal("thoughtbot/dotfiles"))
+		Expect(metadata.Visibility).To(Equal("internal"))
+		Expect(metadata.Topics).To(Equal([]string{"dotfiles", "zsh"}))
+		Expect(metadata.Size).To(Equal(108))
+		Expect(metadata.PushedAt).To(Equal(time.Date(2021, 11, 2, 10, 0, 0, 0, time.UTC)))
+		Expect(metadata.UpdatedAt).To(Equal(time.Date(2021, 11, 3, 10, 0, 0, 0, time.UTC)))
+
+		Expect(repositories[1].(upstream.MetadataReporter).GetMetadata().Visibility).To(Equal("public"))
+	})
+})
+
+var _ = Describe("Github repo via GraphQL with an unknown owner", func() {
+	var client *upstream.GithubClient
+	var repositories []upstream.HostRepository
+	var err error
+	BeforeEach(func() {
+		httpClient := mock.NewHTTPClient().
+			Mock("GET", "/user",
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`{"login": "octocat", "id": 1}`))
+				},
+			).
+			Mock("POST", "/graphql",
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`{
+						"data": {"rateLimit": {"remaining": 4999}, "owner": null},
+						"errors": [{"type": "NOT_FOUND", "message": "Could not resolve to a RepositoryOwner with the login of 'ghost'."}]
+					}`))
+				},
+			).Client()
+		client = upstream.NewGithubClient(github.NewClient(httpClient)).WithGraphQL(httpClient)
+		repositories, err = client.GetRepositories(context.Background(), []string{"ghost"}, false)
+	})
+	It("Fails rather than reporting the owner without repositories", func() {
+		var partial *upstream.PartialError
+		Expect(errors.As(err, &partial)).To(BeTrue())
+		Expect(partial.Failed).To(HaveLen(1))
+		Expect(partial.Failed[0].Owner).To(Equal("ghost"))
+		Expect(partial.Failed[0].Err).To(MatchError(ContainSubstring("owner ghost not found")))
+		Expect(repositories).To(BeEmpty())
+		Expect(client.FetchResults()[0].Complete).To(BeFalse())
+	})
+})
@@ -2,32 +2,84 @@ package upstream
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"net/http"
 	"net/url"
+	"path"
 	"path/filepath"
+	"strconv"
 	"sync"
+	"time"
 
+	"github.com/hashicorp/go-retryablehttp"
 	"github.com/xanzy/go-gitlab"
-	"golang.org/x/sync/errgroup"
 )
 
 const gitlabPageSize = 100
-const gitlabUpstream = "gitlab.com"
+const gitlabHost = "gitlab.com"
 
 type GitlabProject struct {
 	gitlab.Project
+	// the owner if the namespace of the project is unknown
 	Username string
+	Provider string
+	// whether the project was fetched because the user starred it
+	Starred bool
+}
+
+func (r *GitlabProject) IsStarred() bool {
+	return r.Starred
+}
+
+// GetMetadata returns the metadata of the project. GitLab reports neither the
+// language of projects nor when they were last pushed to when listing them,
+// so the time of the last activity is used for both PushedAt and UpdatedAt.
+func (r *GitlabProject) GetMetadata() Metadata {
+	metadata := Metadata{
+		Stars:         r.Project.StarCount,
+		DefaultBranch: r.Project.DefaultBranch,
+		Archived:      r.Project.Archived,
+		Fork:          r.Project.ForkedFromProject != nil,
+		Visibility:    string(r.Project.Visibility),
+		Topics:        r.Project.Topics,
+	}
+
+	if r.Project.ID != 0 {
+		metadata.ID = strconv.Itoa(r.Project.ID)
+	}
+	if r.Project.ForkedFromProject != nil {
+		metadata.Parent = r.Project.ForkedFromProject.PathWithNamespace
+	}
+	if len(metadata.Topics) == 0 {
+		// GitLab before 14.0 only reports topics as tags
+		metadata.Topics = r.Project.TagList
+	}
+	if r.Project.Statistics != nil {
+		metadata.Size = int(r.Project.Statistics.RepositorySize / 1024)
+	}
+	if r.Project.LastActivityAt != nil {
+		metadata.PushedAt = *r.Project.LastActivityAt
+		metadata.UpdatedAt = *r.Project.LastActivityAt
+	}
+
+	return metadata
 }
 
 func (r *GitlabProject) GetProvider() string {
-	return "gitlab"
+	return r.Provider
 }
 
 func (r *GitlabProject) GetName() string {
 	return r.Project.Path
 }
 
+// GetOwner returns the full path of the namespace of the project, e.g.
+// group/subgroup for projects of subgroups
 func (r *GitlabProject) GetOwner() string {
+	if r.Project.Namespace != nil && r.Project.Namespace.FullPath != "" {
+		return r.Project.Namespace.FullPath
+	}
 	return r.Username
 }
 
@@ -78,24 +130,91 @@ func (r *GitlabProject) GetSSHCloneURL() string {
 }
 
 type GitlabClient struct {
+	updateTracker
+	fetchResults
+
 	client   *gitlab.Client
+	host     string // the hostname of the GitLab instance
+	provider string // the provider name of the GitLab instance
 	username string // the username of authenticated user
 	userID   int    // the id of authenticated user
+
+	// whether to fetch the groups of the authenticated user in addition to the
+	// configured ones, except for excludeGroups
+	autoDiscoverGroups bool
+	excludeGroups      []string
+
+	// whether to fetch the projects starred by the authenticated user
+	fetchStarred bool
+
+	// the rate limit transport of the client, if created via
+	// NewGitlabClientWithToken
+	rateLimits *RateLimitTransport
 }
 
 func NewGitlabClient(client *gitlab.Client) *GitlabClient {
-	return &GitlabClient{client: client, username: "nobody"}
+	host := client.BaseURL().Hostname()
+	return &GitlabClient{
+		client:   client,
+		host:     host,
+		provider: providerName("gitlab", gitlabHost, host),
+		username: "nobody",
+	}
 }
 
-func NewGitlabClientWithToken(token string) (*GitlabClient, error) {
-	client, err := gitlab.NewClient(token)
+// NewGitlabClientWithToken returns a client for the GitLab instance at baseURL
+// (e.g. https://gitlab.example.com), or for gitlab.com if baseURL is empty.
+// Responses are cached in cacheDir unless it is empty.
+func NewGitlabClientWithToken(token, baseURL, cacheDir string) (*GitlabClient, error) {
+	var options []gitlab.ClientOptionFunc
+	if baseURL != "" {
+		options = append(options, gitlab.WithBaseURL(baseURL))
+	}
+
+	var transport http.RoundTripper
+	if cacheDir != "" {
+		transport = NewCachingTransport(cacheDir, nil)
+	}
+	// go-gitlab already retries rate limited requests
+	rateLimits := NewRateLimitTransport(transport).WithoutRetries()
+	options = append(options, gitlab.WithHTTPClient(&http.Client{Transport: rateLimits}))
+
+	client, err := gitlab.NewClient(token, options...)
 	if err != nil {
 		return nil, err
 	}
 
-	return &GitlabClient{client: client, username: "nobody"}, nil
+	c := NewGitlabClient(client)
+	c.rateLimits = rateLimits
+	return c, nil
 }
 
+// GetRateLimit returns the API quota reported by the last response
+func (c *GitlabClient) GetRateLimit() (RateLimit, bool) {
+	if c.rateLimits == nil {
+		return RateLimit{}, false
+	}
+	return c.rateLimits.RateLimit()
+}
+
+// WithGroupDiscovery makes the client fetch the projects of all groups the
+// authenticated user is a member of, except for the excluded ones
+func (c *GitlabClient) WithGroupDiscovery(exclude []string) *GitlabClient {
+	c.autoDiscoverGroups = true
+	c.excludeGroups = exclude
+	return c
+}
+
+// WithStarred makes the client fetch the projects starred by the
+// authenticated user
+func (c *GitlabClient) WithStarred() *GitlabClient {
+	c.fetchStarred = true
+	return c
+}
+
+// GetRepositories fetches the projects of the groups concurrently. When
+// fetching the projects of some groups fails, the projects of the other groups
+// are returned along with a PartialError.
 func (c *GitlabClient) GetRepositories(ctx context.Context, groups []string, fetchUserRepos bool) ([]HostRepository, error) {
 	res := HostRepositories{}
 	var m sync.Map
@@ -104,47 +223,81 @@ func (c *GitlabClient) GetRepositories(ctx context.Context, groups []string, fet
 		return nil, err
 	}
 
-	logAuthenticatedUser(gitlabUpstream, c.username)
+	logAuthenticatedUser(c.host, c.username)
+	c.reset()
+
+	if c.autoDiscoverGroups {
+		memberGroups, err := c.getMemberGroups(ctx)
+		if err != nil {
+			c.record(c.username, false, time.Time{}, nil, fmt.Errorf("discovering groups: %w", err))
+		}
+		groups = mergeOwners(groups, memberGroups, c.excludeGroups)
+	}
+
+	var wg sync.WaitGroup
+
+	// the starred projects are appended after the projects of the groups, so
+	// that projects which are also fetched via their group are not marked as
+	// starred when de-duplicating
+	var starred []HostRepository
+	if c.fetchStarred {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var err error
+			starred, err = c.getStarredProjects(ctx)
+			c.record(c.username, true, time.Time{}, starred, err)
+		}()
+	}
 
-	var g errgroup.Group
 	if fetchUserRepos {
-		g.Go(func(ctx context.Context) func() error {
-			return func() error {
-				userProjects, err := c.getProjectsForAuthUser(ctx, c.userID, c.username)
-				if err != nil {
-					return err
-				}
-
-				m.Store(c.username, userProjects)
-				return nil
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			userProjects, err := c.getProjectsForAuthUser(ctx, c.userID, c.username)
+			c.record(c.username, false, c.updatedSince(""), userProjects, err)
+			if err != nil {
+				return
 			}
-		}(ctx))
+
+			c.fetched("", userProjects)
+			m.Store(c.username, userProjects)
+		}()
 	}
 
 	for _, group := range groups {
-		g.Go(func(group string) func() error {
-			return func() error {
-				repos, err := c.getProjectsForGroup(ctx, group)
-				if err != nil {
-					return err
-				}
-
-				m.Store(group, repos)
-				return nil
+		wg.Add(1)
+		go func(group string) {
+			defer wg.Done()
+			repos, err := c.getProjectsForGroup(ctx, group)
+			c.record(group, false, c.updatedSince(group), repos, err)
+			if err != nil {
+				return
 			}
-		}(group))
+
+			c.fetched(group, repos)
+			m.Store(group, repos)
+		}(group)
 	}
 
-	if err := g.Wait(); err != nil {
-		return nil, err
+	wg.Wait()
+
+	// the projects of the fetched groups are not only starred, even if they
+	// were not fetched via their group as they were not updated since the
+	// last fetch
+	for _, repo := range starred {
+		if p := repo.(*GitlabProject); ownedBy(p.GetOwner(), groups) || (fetchUserRepos && ownedBy(p.GetOwner(), []string{c.username})) {
+			p.Starred = false
+		}
 	}
 
 	m.Range(func(key, value interface{}) bool {
 		res = append(res, value.([]HostRepository)...)
 		return true
 	})
+	res = append(res, starred...)
 
-	return res.DeDuplicate(), nil
+	return res.DeDuplicate(), c.err()
 }
 
 func (c *GitlabClient) getProjectsForAuthUser(ctx context.Context, userID int, username string) ([]HostRepository, error) {
@@ -153,17 +306,21 @@ func (c *GitlabClient) getProjectsForAuthUser(ctx context.Context, userID int, u
 			PerPage: gitlabPageSize,
 			Page:    1,
 		},
+		Statistics: gitlab.Bool(true),
+	}
+	if since := c.updatedSince(""); !since.IsZero() {
+		opt.LastActivityAfter = &since
 	}
 
 	var allProjects []*gitlab.Project
 	for {
 		// Get the first page with projects.
-		projects, resp, err := c.client.Projects.ListUserProjects(userID, opt)
+		projects, resp, err := c.client.Projects.ListUserProjects(userID, opt, gitlab.WithContext(ctx))
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 
-		logPaginationStatus(gitlabUpstream, username, len(projects), resp.TotalPages-resp.NextPage-1, resp.Header.Get("RateLimit-Remaining"))
+		logPaginationStatus(c.host, username, len(projects), resp.TotalPages-resp.NextPage-1, resp.Header.Get("RateLimit-Remaining"))
 
 		allProjects = append(allProjects, projects...)
 
@@ -176,17 +333,26 @@ func (c *GitlabClient) getProjectsForAuthUser(ctx context.Context, userID int, u
 
 	repos := make([]HostRepository, len(allProjects))
 	for i, p := range allProjects {
-		repos[i] = &GitlabProject{Project: *p, Username: username}
+		repos[i] = &GitlabProject{Project: *p, Username: username, Provider: c.provider}
 	}
 	return repos, nil
 }
 
+// getProjectsForGroup fetches the projects of a group and of all its
+// subgroups
 func (c *GitlabClient) getProjectsForGroup(ctx context.Context, group string) ([]HostRepository, error) {
 	opt := &gitlab.ListGroupProjectsOptions{
 		ListOptions: gitlab.ListOptions{
 			PerPage: gitlabPageSize,
 			Page:    1,
 		},
+		IncludeSubgroups: gitlab.Bool(true),
+	}
+
+	// the statistics of projects include their size
+	options := []gitlab.RequestOptionFunc{gitlab.WithContext(ctx), withQueryParam("statistics", "true")}
+	if since := c.updatedSince(group); !since.IsZero() {
+		options = append(options, withQueryParam("last_activity_after", since.UTC().Format(time.RFC3339)))
 	}
 
 	var allProjects []*gitlab.Project
@@ -194,12 +360,13 @@ func (c *GitlabClient) getProjectsForGroup(ctx context.Context, group string) ([
 		groupProjects, resp, err := c.client.Groups.ListGroupProjects(
 			group,
 			opt,
+			options...,
 		)
 		if err != nil {
 			return nil, err
 		}
 
-		logPaginationStatus(gitlabUpstream, group, len(groupProjects), resp.TotalPages-resp.NextPage-1, resp.Header.Get("RateLimit-Remaining"))
+		logPaginationStatus(c.host, group, len(groupProjects), resp.TotalPages-resp.NextPage-1, resp.Header.Get("RateLimit-Remaining"))
 
 		allProjects = append(allProjects, groupProjects...)
 
@@ -212,14 +379,94 @@ func (c *GitlabClient) getProjectsForGroup(ctx context.Context, group string) ([
 
 	repos := make([]HostRepository, len(allProjects))
 	for i, p := range allProjects {
-		repos[i] = &GitlabProject{*p, group}
+		repos[i] = &GitlabProject{Project: *p, Username: group, Provider: c.provider}
+	}
+	return repos, nil
+}
+
+// withQueryParam sets a query parameter of the API which is missing from the
+// options of go-gitlab e.g. statistics and last_activity_after of
+// ListGroupProjectsOptions
+func withQueryParam(key, value string) gitlab.RequestOptionFunc {
+	return func(req *retryablehttp.Request) error {
+		query := req.URL.Query()
+		query.Set(key, value)
+		req.URL.RawQuery = query.Encode()
+		return nil
+	}
+}
+
+// getStarredProjects fetches the projects starred by the authenticated user
+func (c *GitlabClient) getStarredProjects(ctx context.Context) ([]HostRepository, error) {
+	opt := &gitlab.ListProjectsOptions{
+		ListOptions: gitlab.ListOptions{
+			PerPage: gitlabPageSize,
+			Page:    1,
+		},
+		Starred:    gitlab.Bool(true),
+		Statistics: gitlab.Bool(true),
+	}
+
+	var allProjects []*gitlab.Project
+	for {
+		projects, resp, err := c.client.Projects.ListProjects(opt, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		logPaginationStatus(c.host, "starred", len(projects), resp.TotalPages-resp.NextPage-1, resp.Header.Get("RateLimit-Remaining"))
+
+		allProjects = append(allProjects, projects...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	repos := make([]HostRepository, len(allProjects))
+	for i, p := range allProjects {
+		repos[i] = &GitlabProject{Project: *p, Username: path.Dir(p.PathWithNamespace), Provider: c.provider, Starred: true}
 	}
 	return repos, nil
 }
 
+// getMemberGroups fetches the full paths of the groups the authenticated user
+// is a member of. Subgroups of member groups are left out, as their projects
+// are fetched along with the projects of the parent group.
+func (c *GitlabClient) getMemberGroups(ctx context.Context) ([]string, error) {
+	var paths []string
+	opt := &gitlab.ListGroupsOptions{
+		ListOptions: gitlab.ListOptions{
+			PerPage: gitlabPageSize,
+			Page:    1,
+		},
+		// only the groups of the user, even for administrators
+		MinAccessLevel: gitlab.AccessLevel(gitlab.GuestPermissions),
+	}
+
+	for {
+		groups, resp, err := c.client.Groups.ListGroups(opt, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, group := range groups {
+			paths = append(paths, group.FullPath)
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	paths = topLevelPaths(paths)
+	log.Printf("Discovered %d groups on %s", len(paths), c.host)
+	return paths, nil
+}
+
 // setUserInfo fetches the authenticated user's information and stores it
 func (c *GitlabClient) setUserInfo(ctx context.Context) error {
-	user, _, err := c.client.Users.CurrentUser()
+	user, _, err := c.client.Users.CurrentUser(gitlab.WithContext(ctx))
 	if err != nil {
 		return err
 	}
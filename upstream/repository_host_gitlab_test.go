@@ -3,6 +3,7 @@ package upstream_test
 import (
 	"context"
 	"net/http"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -16,9 +17,12 @@ var _ = Describe("Gitlab repo", func() {
 	var client *upstream.GitlabClient
 	var gitlabClient *gitlab.Client
 	var repositories []upstream.HostRepository
+	var httpClient *http.Client
+	var includeSubgroups string
+	var statistics string
 	var err error
 	BeforeEach(func() {
-		httpClient := mock.NewHTTPClient().
+		httpClient = mock.NewHTTPClient().
 			Mock("GET", "/api/v4/user",
 				func(w http.ResponseWriter, r *http.Request) {
 					_, _ = w.Write([]byte(`
@@ -48,6 +52,8 @@ var _ = Describe("Gitlab repo", func() {
 			).
 			Mock("GET", "/api/v4/groups/greatuser/projects",
 				func(w http.ResponseWriter, r *http.Request) {
+					includeSubgroups = r.URL.Query().Get("include_subgroups")
+					statistics = r.URL.Query().Get("statistics")
 					_, _ = w.Write([]byte(`
 						[
 						  {
@@ -69,7 +75,23 @@ var _ = Describe("Gitlab repo", func() {
 							"http_url_to_repo": "https://gitlab.com/greatuser/personal-website",
 							"web_url": "https://gitlab.com/greatuser/personal-website",
 							"name": "personal-website",
-							"path": "personal-website"
+							"path": "personal-website",
+							"star_count": 3,
+							"archived": true,
+							"topics": ["blog", "hugo"],
+							"last_activity_at": "2021-11-02T10:00:00Z",
+							"forked_from_project": {"id": 1, "path_with_namespace": "hugo/starter"},
+							"statistics": {"repository_size": 2097152}
+						  },
+						  {
+							"id": 11,
+							"description": "my vim config",
+							"ssh_url_to_repo": "git@gitlab.com:greatuser/config/editors/vim.git",
+							"http_url_to_repo": "https://gitlab.com/greatuser/config/editors/vim",
+							"web_url": "https://gitlab.com/greatuser/config/editors/vim",
+							"name": "vim",
+							"path": "vim",
+							"namespace": {"id": 12, "path": "editors", "kind": "group", "full_path": "greatuser/config/editors"}
 						  }
 						]`,
 					))
@@ -82,7 +104,7 @@ var _ = Describe("Gitlab repo", func() {
 		Expect(err).To(BeNil())
 	})
 	It("Returns the matching repositories", func() {
-		Expect(len(repositories)).To(Equal(2))
+		Expect(len(repositories)).To(Equal(3))
 		Expect(repositories[0].GetOwner()).To(Equal("greatuser"))
 		Expect(repositories[0].GetName()).To(Equal("dotfiles"))
 		Expect(repositories[0].GetDescription()).To(Equal("my dotfiles"))
@@ -110,4 +132,134 @@ var _ = Describe("Gitlab repo", func() {
 		Expect(repositories[0].GetProvider()).To(Equal("gitlab"))
 		Expect(repositories[1].GetProvider()).To(Equal("gitlab"))
 	})
+	It("Returns the metadata of the projects", func() {
+		Expect(statistics).To(Equal("true"))
+		metadata := repositories[1].(upstream.MetadataReporter).GetMetadata()
+		Expect(metadata.ID).To(Equal("10"))
+		Expect(metadata.Stars).To(Equal(3))
+		Expect(metadata.DefaultBranch).To(Equal("master"))
+		Expect(metadata.Archived).To(BeTrue())
+		Expect(metadata.Fork).To(BeTrue())
+		Expect(metadata.Parent).To(Equal("hugo/starter"))
+		Expect(metadata.Visibility).To(Equal("internal"))
+		Expect(metadata.Topics).To(Equal([]string{"blog", "hugo"}))
+		Expect(metadata.Size).To(Equal(2048))
+		Expect(metadata.PushedAt).To(Equal(time.Date(2021, 11, 2, 10, 0, 0, 0, time.UTC)))
+		Expect(repositories[0].(upstream.MetadataReporter).GetMetadata().Fork).To(BeFalse())
+	})
+	It("Returns the projects of subgroups with the full namespace path as owner", func() {
+		Expect(includeSubgroups).To(Equal("true"))
+		Expect(repositories[2].GetOwner()).To(Equal("greatuser/config/editors"))
+		Expect(repositories[2].GetName()).To(Equal("vim"))
+		Expect(repositories[2].GetOrgURL()).To(Equal("https://gitlab.com/greatuser/config/editors"))
+	})
+	It("Uses the hostname of self-hosted instances as provider", func() {
+		gitlabClient, err = gitlab.NewClient("sometoken",
+			gitlab.WithHTTPClient(httpClient),
+			gitlab.WithBaseURL("https://gitlab.example.com"),
+		)
+		Expect(err).To(BeNil())
+		repositories, err = upstream.NewGitlabClient(gitlabClient).GetRepositories(context.Background(), []string{"greatuser"}, false)
+		Expect(err).To(BeNil())
+		Expect(len(repositories)).To(Equal(3))
+		Expect(repositories[0].GetProvider()).To(Equal("gitlab.example.com"))
+		Expect(repositories[1].GetProvider()).To(Equal("gitlab.example.com"))
+	})
+})
+
+var _ = Describe("Gitlab repo with group discovery", func() {
+	var repositories []upstream.HostRepository
+	BeforeEach(func() {
+		projectsHandler := func(group string) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(`[
+					{
+						"id": 1,
+						"path": "dotfiles",
+						"web_url": "https://gitlab.com/` + group + `/dotfiles",
+						"namespace": {"full_path": "` + group + `"}
+					}
+				]`))
+			}
+		}
+		httpClient := mock.NewHTTPClient().
+			Mock("GET", "/api/v4/user",
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`{"id": 1, "username": "john_smith"}`))
+				},
+			).
+			Mock("GET", "/api/v4/groups",
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`[
+						{"id": 2, "full_path": "discovered"},
+						{"id": 3, "full_path": "discovered/subgroup"},
+						{"id": 4, "full_path": "noisy"}
+					]`))
+				},
+			).
+			Mock("GET", "/api/v4/groups/discovered/projects", projectsHandler("discovered")).
+			Client()
+		gitlabClient, err := gitlab.NewClient("sometoken", gitlab.WithHTTPClient(httpClient))
+		Expect(err).To(BeNil())
+		client := upstream.NewGitlabClient(gitlabClient).WithGroupDiscovery([]string{"noisy"})
+		repositories, err = client.GetRepositories(context.Background(), []string{}, false)
+		Expect(err).To(BeNil())
+	})
+	It("Returns the projects of the top-level member groups except the excluded ones", func() {
+		Expect(len(repositories)).To(Equal(1))
+		Expect(repositories[0].GetOwner()).To(Equal("discovered"))
+		Expect(repositories[0].GetName()).To(Equal("dotfiles"))
+	})
+})
+
+// fetchContextKey is the key of a value of the context of GetRepositories
+type fetchContextKey struct{}
+
+var _ = Describe("Gitlab repo fetched incrementally", func() {
+	var client *upstream.GitlabClient
+	var lastActivityAfter string
+	// the contexts of the requests for the user and the group projects
+	var contexts []interface{}
+	BeforeEach(func() {
+		contexts = nil
+		httpClient := mock.NewHTTPClient().
+			Mock("GET", "/api/v4/user",
+				func(w http.ResponseWriter, r *http.Request) {
+					contexts = append(contexts, r.Context().Value(fetchContextKey{}))
+					_, _ = w.Write([]byte(`{"id": 1, "username": "john_smith"}`))
+				},
+			).
+			Mock("GET", "/api/v4/groups/greatgroup/projects",
+				func(w http.ResponseWriter, r *http.Request) {
+					contexts = append(contexts, r.Context().Value(fetchContextKey{}))
+					lastActivityAfter = r.URL.Query().Get("last_activity_after")
+					_, _ = w.Write([]byte(`[
+						{
+							"id": 1,
+							"path": "dotfiles",
+							"web_url": "https://gitlab.com/greatgroup/dotfiles",
+							"namespace": {"full_path": "greatgroup"},
+							"last_activity_at": "2021-11-03T10:00:00Z"
+						}
+					]`))
+				},
+			).
+			Client()
+		gitlabClient, err := gitlab.NewClient("sometoken", gitlab.WithHTTPClient(httpClient))
+		Expect(err).To(BeNil())
+		client = upstream.NewGitlabClient(gitlabClient)
+		client.SetUpdatedSince(map[string]time.Time{"greatgroup": time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC)})
+		ctx := context.WithValue(context.Background(), fetchContextKey{}, "fetch")
+		_, err = client.GetRepositories(ctx, []string{"greatgroup"}, false)
+		Expect(err).To(BeNil())
+	})
+	It("Sends the requests with the context of the fetch", func() {
+		Expect(contexts).To(Equal([]interface{}{"fetch", "fetch"}))
+	})
+	It("Fetches only the projects with activity since the last fetch", func() {
+		Expect(lastActivityAfter).To(Equal("2021-11-01T00:00:00Z"))
+		Expect(client.UpdatedUntil()).To(Equal(map[string]time.Time{
+			"greatgroup": time.Date(2021, 11, 3, 10, 0, 0, 0, time.UTC),
+		}))
+	})
 })
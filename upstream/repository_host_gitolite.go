@@ -0,0 +1,292 @@
+package upstream
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh"
+)
+
+const gitoliteDefaultUser = "git"
+const gitoliteDefaultPort = "22"
+
+// GitoliteRepository is a repository listed by the info command of gitolite
+type GitoliteRepository struct {
+	Name   string
+	Access string
+
+	// the gitolite server e.g. git@git.example.com
+	user string
+	host string
+	port string
+}
+
+func (r *GitoliteRepository) GetProvider() string {
+	return r.host
+}
+
+func (r *GitoliteRepository) GetName() string {
+	return path.Base(r.Name)
+}
+
+// GetOwner returns the directory of the repository, or the SSH user of
+// gitolite for repositories which are not in a directory
+func (r *GitoliteRepository) GetOwner() string {
+	owner := path.Dir(r.Name)
+	if owner == "." {
+		return r.user
+	}
+	return owner
+}
+
+func (r *GitoliteRepository) GetDescription() string {
+	return ""
+}
+
+func (r *GitoliteRepository) GetBrowserHomepageURL() string {
+	return ""
+}
+
+func (r *GitoliteRepository) GetBrowserPullRequestsURL() string {
+	return ""
+}
+
+func (r *GitoliteRepository) GetOrgURL() string {
+	return ""
+}
+
+func (r *GitoliteRepository) GetIssuesURL() string {
+	return ""
+}
+
+func (r *GitoliteRepository) GetCIURL() string {
+	return ""
+}
+
+func (r *GitoliteRepository) GetReleasesURL() string {
+	return ""
+}
+
+func (r *GitoliteRepository) GetSettingsURL() string {
+	return ""
+}
+
+// GetHTTPSCloneURL returns an empty string, as gitolite is only accessible
+// via SSH
+func (r *GitoliteRepository) GetHTTPSCloneURL() string {
+	return ""
+}
+
+func (r *GitoliteRepository) GetSSHCloneURL() string {
+	if r.port != gitoliteDefaultPort {
+		return fmt.Sprintf("ssh://%s@%s:%s/%s", r.user, r.host, r.port, r.Name)
+	}
+	return fmt.Sprintf("%s@%s:%s", r.user, r.host, r.Name)
+}
+
+// GetAccess returns whether the authenticated user can push to the repository
+func (r *GitoliteRepository) GetAccess() string {
+	return r.Access
+}
+
+// GitoliteCommand runs a gitolite command on the server and returns its output
+type GitoliteCommand func(ctx context.Context, command string) ([]byte, error)
+
+// GitoliteClient lists the repositories accessible on a gitolite server
+type GitoliteClient struct {
+	fetchResults
+	run  GitoliteCommand
+	user string
+	host string
+	port string
+}
+
+// NewGitoliteClient returns a client of the gitolite server at sshURL (e.g.
+// git@git.example.com or ssh://git@git.example.com:2222), running commands
+// with run
+func NewGitoliteClient(sshURL string, run GitoliteCommand) (*GitoliteClient, error) {
+	user, host, port, err := parseGitoliteURL(sshURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GitoliteClient{run: run, user: user, host: host, port: port}, nil
+}
+
+// NewGitoliteClientWithSSHAuth returns a client running commands over SSH,
+// authenticated via ssh-agent or with the private key at privKeyPath
+func NewGitoliteClientWithSSHAuth(sshURL string, useSSHAgent bool, privKeyPath string) (*GitoliteClient, error) {
+	user, host, port, err := parseGitoliteURL(sshURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var auth gitssh.AuthMethod
+	switch {
+	case privKeyPath != "":
+		auth, err = gitssh.NewPublicKeysFromFile(user, privKeyPath, "")
+	case useSSHAgent:
+		auth, err = gitssh.NewSSHAgentAuth(user)
+	default:
+		return nil, fmt.Errorf("gitolite requires ssh authentication (ogit.sshAuth)")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewGitoliteClient(sshURL, func(ctx context.Context, command string) ([]byte, error) {
+		return runSSHCommand(ctx, net.JoinHostPort(host, port), auth, command)
+	})
+}
+
+// GetRepositories returns the repositories which the authenticated user can
+// access, limited to the directories in owners if any are configured. All
+// accessible repositories are returned if fetchUserRepos is true.
+func (c *GitoliteClient) GetRepositories(ctx context.Context, owners []string, fetchUserRepos bool) ([]HostRepository, error) {
+	c.reset()
+	output, err := c.run(ctx, "info")
+	if err != nil {
+		return nil, fmt.Errorf("gitolite info failed: %w", err)
+	}
+
+	username, repos := parseGitoliteInfo(output)
+	logAuthenticatedUser(c.host, username)
+
+	wanted := map[string]bool{}
+	for _, owner := range owners {
+		wanted[owner] = true
+	}
+
+	res := HostRepositories{}
+	byOwner := map[string][]HostRepository{}
+	for owner := range wanted {
+		byOwner[owner] = nil
+	}
+	for _, repo := range repos {
+		repo.user, repo.host, repo.port = c.user, c.host, c.port
+		if fetchUserRepos || wanted[repo.GetOwner()] {
+			res = append(res, repo)
+			byOwner[repo.GetOwner()] = append(byOwner[repo.GetOwner()], repo)
+		}
+	}
+
+	// the info command lists all accessible repositories of the owners
+	for owner, ownerRepos := range byOwner {
+		c.record(owner, false, time.Time{}, ownerRepos, nil)
+	}
+
+	logPaginationStatus(c.host, "", len(res), 0, "n/a")
+
+	return res.DeDuplicate(), nil
+}
+
+// parseGitoliteInfo parses the output of the info command, e.g.
+//
+//	hello alice, this is git@git.example.com running gitolite3 v3.6.12 on git 2.30.2
+//
+//	 R W	infra/terraform
+//	 R  	testing
+//
+// Wildcard repositories (e.g. CREATOR/..*) are skipped, as they are patterns
+// rather than repositories.
+func parseGitoliteInfo(output []byte) (string, []*GitoliteRepository) {
+	username := ""
+	repos := []*GitoliteRepository{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "hello ") {
+			if fields := strings.Fields(line); len(fields) > 1 {
+				username = strings.TrimSuffix(fields[1], ",")
+			}
+			continue
+		}
+
+		perms := strings.SplitN(line, "\t", 2)
+		if len(perms) != 2 || strings.ContainsAny(perms[1], "*[]^$") {
+			continue
+		}
+
+		access := AccessUnknown
+		for _, perm := range strings.Fields(perms[0]) {
+			switch {
+			case perm == "W":
+				access = AccessWrite
+			case perm == "R" && access == AccessUnknown:
+				access = AccessRead
+			}
+		}
+
+		if access != AccessUnknown {
+			repos = append(repos, &GitoliteRepository{Name: strings.TrimSpace(perms[1]), Access: access})
+		}
+	}
+
+	return username, repos
+}
+
+// parseGitoliteURL returns the user, host and port of an SSH URL e.g.
+// git@git.example.com or ssh://git@git.example.com:2222
+func parseGitoliteURL(sshURL string) (user, host, port string, err error) {
+	if !strings.Contains(sshURL, "://") {
+		sshURL = "ssh://" + sshURL
+	}
+
+	parsed, err := url.Parse(sshURL)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	if parsed.Hostname() == "" {
+		return "", "", "", fmt.Errorf("invalid gitolite url: %q", sshURL)
+	}
+
+	user, port = gitoliteDefaultUser, gitoliteDefaultPort
+	if parsed.User != nil && parsed.User.Username() != "" {
+		user = parsed.User.Username()
+	}
+	if parsed.Port() != "" {
+		port = parsed.Port()
+	}
+
+	return user, parsed.Hostname(), port, nil
+}
+
+// runSSHCommand runs command on the SSH server at addr and returns its output
+func runSSHCommand(ctx context.Context, addr string, auth gitssh.AuthMethod, command string) ([]byte, error) {
+	config, err := auth.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	return session.Output(command)
+}
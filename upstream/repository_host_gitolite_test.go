@@ -0,0 +1,75 @@
+package upstream_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/wmalik/ogit/upstream"
+)
+
+var _ = Describe("Gitolite repo", func() {
+	var command string
+	var client *upstream.GitoliteClient
+	var err error
+	BeforeEach(func() {
+		client, err = upstream.NewGitoliteClient("git@git.example.com",
+			func(ctx context.Context, c string) ([]byte, error) {
+				command = c
+				return []byte("hello alice, this is git@git.example.com running gitolite3 v3.6.12-0-gf3e1a2b on git 2.30.2\n" +
+					"\n" +
+					" R W\tgitolite-admin\n" +
+					" R W\tinfra/terraform\n" +
+					" R  \tinfra/scripts\n" +
+					" R  \tmirrors/linux\n" +
+					" C R W\tusers/CREATOR/..*\n"), nil
+			},
+		)
+		Expect(err).To(BeNil())
+	})
+	It("Returns the repositories of the configured owners with their access", func() {
+		repositories, err := client.GetRepositories(context.Background(), []string{"infra"}, false)
+		Expect(err).To(BeNil())
+		Expect(command).To(Equal("info"))
+		Expect(len(repositories)).To(Equal(2))
+		Expect(repositories[0].GetProvider()).To(Equal("git.example.com"))
+		Expect(repositories[0].GetOwner()).To(Equal("infra"))
+		Expect(repositories[0].GetName()).To(Equal("terraform"))
+		Expect(repositories[0].GetSSHCloneURL()).To(Equal("git@git.example.com:infra/terraform"))
+		Expect(repositories[0].(upstream.AccessReporter).GetAccess()).To(Equal(upstream.AccessWrite))
+		Expect(repositories[1].GetName()).To(Equal("scripts"))
+		Expect(repositories[1].(upstream.AccessReporter).GetAccess()).To(Equal(upstream.AccessRead))
+	})
+	It("Returns all accessible repositories except wildcards if user repos are fetched", func() {
+		repositories, err := client.GetRepositories(context.Background(), []string{}, true)
+		Expect(err).To(BeNil())
+		Expect(len(repositories)).To(Equal(4))
+		Expect(repositories[0].GetOwner()).To(Equal("git"))
+		Expect(repositories[0].GetName()).To(Equal("gitolite-admin"))
+	})
+	It("Uses the ssh URL syntax for non-default ports", func() {
+		client, err = upstream.NewGitoliteClient("ssh://gitolite@git.example.com:2222",
+			func(ctx context.Context, c string) ([]byte, error) {
+				return []byte(" R W\tdotfiles\n"), nil
+			},
+		)
+		Expect(err).To(BeNil())
+		repositories, err := client.GetRepositories(context.Background(), []string{}, true)
+		Expect(err).To(BeNil())
+		Expect(repositories[0].GetOwner()).To(Equal("gitolite"))
+		Expect(repositories[0].GetSSHCloneURL()).To(Equal("ssh://gitolite@git.example.com:2222/dotfiles"))
+	})
+	It("Ignores a greeting without username", func() {
+		client, err = upstream.NewGitoliteClient("git@git.example.com",
+			func(ctx context.Context, c string) ([]byte, error) {
+				return []byte("hello \n R W\tdotfiles\n"), nil
+			},
+		)
+		Expect(err).To(BeNil())
+		repositories, err := client.GetRepositories(context.Background(), []string{}, true)
+		Expect(err).To(BeNil())
+		Expect(len(repositories)).To(Equal(1))
+		Expect(repositories[0].GetName()).To(Equal("dotfiles"))
+	})
+})
@@ -0,0 +1,198 @@
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultGitDescription is the content of the description file created by git
+// init, which is not a meaningful description
+const defaultGitDescription = "Unnamed repository; edit this file 'description' to name the repository."
+
+// LocalRepository is a bare repository in a directory on disk
+type LocalRepository struct {
+	// the absolute path of the bare repository e.g. /srv/git/dotfiles.git
+	Path        string
+	Description string
+}
+
+func (r *LocalRepository) GetProvider() string {
+	return "local"
+}
+
+// GetName returns the name of the directory without the .git suffix
+func (r *LocalRepository) GetName() string {
+	return strings.TrimSuffix(filepath.Base(r.Path), ".git")
+}
+
+// GetOwner returns the name of the scanned directory e.g. git for /srv/git
+func (r *LocalRepository) GetOwner() string {
+	return filepath.Base(filepath.Dir(r.Path))
+}
+
+func (r *LocalRepository) GetDescription() string {
+	return r.Description
+}
+
+func (r *LocalRepository) GetBrowserHomepageURL() string {
+	return ""
+}
+
+func (r *LocalRepository) GetBrowserPullRequestsURL() string {
+	return ""
+}
+
+func (r *LocalRepository) GetOrgURL() string {
+	return ""
+}
+
+func (r *LocalRepository) GetIssuesURL() string {
+	return ""
+}
+
+func (r *LocalRepository) GetCIURL() string {
+	return ""
+}
+
+func (r *LocalRepository) GetReleasesURL() string {
+	return ""
+}
+
+func (r *LocalRepository) GetSettingsURL() string {
+	return ""
+}
+
+// GetHTTPSCloneURL returns the file:// URL of the repository, as it is cloned
+// from disk regardless of the configured authentication
+func (r *LocalRepository) GetHTTPSCloneURL() string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(r.Path)}).String()
+}
+
+func (r *LocalRepository) GetSSHCloneURL() string {
+	return r.GetHTTPSCloneURL()
+}
+
+// LocalClient lists the bare repositories of directories on disk
+type LocalClient struct {
+	fetchResults
+	dirs []string
+}
+
+func NewLocalClient(dirs []string) *LocalClient {
+	return &LocalClient{dirs: dirs}
+}
+
+// NewLocalClientWithPaths returns a client scanning the comma separated list
+// of directories in paths
+func NewLocalClientWithPaths(paths string) (*LocalClient, error) {
+	dirs := []string{}
+	for _, dir := range strings.Split(paths, ",") {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("no paths configured")
+	}
+
+	return NewLocalClient(dirs), nil
+}
+
+// GetRepositories returns the bare repositories of the configured directories,
+// limited to the directories named after owners if any are configured. Local
+// directories have no notion of user repositories, so fetchUserRepos is
+// ignored. When scanning some directories fails, the repositories of the
+// other directories are returned along with a PartialError.
+func (c *LocalClient) GetRepositories(ctx context.Context, owners []string, fetchUserRepos bool) ([]HostRepository, error) {
+	wanted := map[string]bool{}
+	for _, owner := range owners {
+		wanted[owner] = true
+	}
+
+	c.reset()
+	res := HostRepositories{}
+	for _, dir := range c.dirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			c.record(filepath.Base(dir), false, time.Time{}, nil, err)
+			continue
+		}
+
+		if len(wanted) > 0 && !wanted[filepath.Base(abs)] {
+			continue
+		}
+
+		// the repositories of a directory are owned by its name
+		repos, err := scanBareRepositories(abs)
+		c.record(filepath.Base(abs), false, time.Time{}, repos, err)
+		if err != nil {
+			continue
+		}
+
+		logPaginationStatus("local", abs, len(repos), 0, "n/a")
+		res = append(res, repos...)
+	}
+
+	return res.DeDuplicate(), c.err()
+}
+
+// scanBareRepositories returns the bare repositories directly inside dir
+func scanBareRepositories(dir string) ([]HostRepository, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	repos := []HostRepository{}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !entry.IsDir() || !isBareRepository(path) {
+			continue
+		}
+
+		repos = append(repos, &LocalRepository{
+			Path:        path,
+			Description: readDescription(path),
+		})
+	}
+
+	return repos, nil
+}
+
+// isBareRepository checks if path contains the HEAD file and the objects and
+// refs directories of a bare repository
+func isBareRepository(path string) bool {
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(path, name)); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// readDescription returns the content of the description file of a bare
+// repository, ignoring the default description of git
+func readDescription(path string) string {
+	raw, err := os.ReadFile(filepath.Join(path, "description"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("unable to read description of %s: %s", path, err)
+		}
+		return ""
+	}
+
+	description := strings.TrimSpace(string(raw))
+	if description == defaultGitDescription {
+		return ""
+	}
+
+	return description
+}
@@ -0,0 +1,73 @@
+package upstream_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/wmalik/ogit/upstream"
+)
+
+var _ = Describe("Local repo", func() {
+	var dir string
+	var repositories []upstream.HostRepository
+	var err error
+
+	// writeBareRepository creates the skeleton of a bare repository
+	writeBareRepository := func(path string, description string) {
+		Expect(os.MkdirAll(filepath.Join(path, "objects"), 0o755)).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(path, "refs"), 0o755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(path, "HEAD"), []byte("ref: refs/heads/main\n"), 0o644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(path, "description"), []byte(description), 0o644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		dir, err = os.MkdirTemp("", "ogit-local")
+		Expect(err).To(BeNil())
+
+		writeBareRepository(filepath.Join(dir, "git", "dotfiles.git"), "my dotfiles\n")
+		writeBareRepository(filepath.Join(dir, "git", "notes"),
+			"Unnamed repository; edit this file 'description' to name the repository.\n")
+		Expect(os.MkdirAll(filepath.Join(dir, "git", "not-a-repo"), 0o755)).To(Succeed())
+		writeBareRepository(filepath.Join(dir, "mirrors", "linux.git"), "")
+
+		client := upstream.NewLocalClient([]string{filepath.Join(dir, "git"), filepath.Join(dir, "mirrors")})
+		repositories, err = client.GetRepositories(context.Background(), []string{"git"}, true)
+		Expect(err).To(BeNil())
+	})
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+	It("Returns the bare repositories of the directories of the configured owners", func() {
+		Expect(len(repositories)).To(Equal(2))
+		Expect(repositories[0].GetProvider()).To(Equal("local"))
+		Expect(repositories[0].GetOwner()).To(Equal("git"))
+		Expect(repositories[0].GetName()).To(Equal("dotfiles"))
+		Expect(repositories[0].GetDescription()).To(Equal("my dotfiles"))
+		Expect(repositories[1].GetName()).To(Equal("notes"))
+		Expect(repositories[1].GetDescription()).To(Equal(""))
+	})
+	It("Returns file URLs as clone URLs", func() {
+		cloneURL := "file://" + filepath.ToSlash(filepath.Join(dir, "git", "dotfiles.git"))
+		Expect(repositories[0].GetHTTPSCloneURL()).To(Equal(cloneURL))
+		Expect(repositories[0].GetSSHCloneURL()).To(Equal(cloneURL))
+	})
+	It("Keeps scanning the other directories when a directory is missing", func() {
+		client := upstream.NewLocalClient([]string{filepath.Join(dir, "missing"), filepath.Join(dir, "mirrors")})
+		repositories, err = client.GetRepositories(context.Background(), nil, true)
+		var partial *upstream.PartialError
+		Expect(errors.As(err, &partial)).To(BeTrue())
+		Expect(partial.Failed[0].Owner).To(Equal("missing"))
+		Expect(len(repositories)).To(Equal(1))
+		Expect(repositories[0].GetOwner()).To(Equal("mirrors"))
+
+		results := client.FetchResults()
+		Expect(results).To(HaveLen(2))
+		Expect(results[0].Owner).To(Equal("mirrors"))
+		Expect(results[0].Complete).To(BeTrue())
+	})
+})
@@ -0,0 +1,139 @@
+package upstream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"gopkg.in/yaml.v2"
+)
+
+// defaultManifestProvider is the provider of the repositories of a manifest
+// which specifies neither a provider for the manifest nor for the repository
+const defaultManifestProvider = "manifest"
+
+// Manifest lists repositories of servers without a listing API. It is read
+// from YAML or JSON, and its repositories have the format of the repositories
+// returned by provider plugins.
+type Manifest struct {
+	// optional, the default provider of the repositories
+	Provider     string             `json:"provider" yaml:"provider"`
+	Repositories []PluginRepository `json:"repositories" yaml:"repositories"`
+}
+
+// ManifestClient reads repositories from a manifest on disk or at a URL
+type ManifestClient struct {
+	fetchResults
+	client *http.Client
+	// a path on disk or an http(s) URL
+	location string
+}
+
+func NewManifestClient(client *http.Client, location string) *ManifestClient {
+	return &ManifestClient{client: client, location: location}
+}
+
+// NewManifestClientWithToken returns a client which sends token as a bearer
+// token when downloading a manifest from a URL
+func NewManifestClientWithToken(location, token string) (*ManifestClient, error) {
+	if location == "" {
+		return nil, fmt.Errorf("no manifest path configured")
+	}
+
+	if token == "" {
+		return NewManifestClient(http.DefaultClient, location), nil
+	}
+
+	return NewManifestClient(
+		oauth2.NewClient(
+			context.Background(),
+			oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}),
+		),
+		location,
+	), nil
+}
+
+// GetRepositories returns the repositories of the manifest, limited to owners
+// if any are configured. Manifests have no notion of user repositories, so
+// fetchUserRepos is ignored.
+func (c *ManifestClient) GetRepositories(ctx context.Context, owners []string, fetchUserRepos bool) ([]HostRepository, error) {
+	c.reset()
+	raw, err := c.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest Manifest
+	if err := unmarshalManifest(raw, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", c.location, err)
+	}
+
+	provider := manifest.Provider
+	if provider == "" {
+		provider = defaultManifestProvider
+	}
+
+	wanted := map[string]bool{}
+	for _, owner := range owners {
+		wanted[owner] = true
+	}
+
+	res := HostRepositories{}
+	byOwner := map[string][]HostRepository{}
+	for i := range manifest.Repositories {
+		repo := &manifest.Repositories[i]
+		if repo.Provider == "" {
+			repo.Provider = provider
+		}
+		if err := repo.validate(defaultManifestProvider); err != nil {
+			return nil, fmt.Errorf("invalid manifest %s: %w", c.location, err)
+		}
+
+		if len(wanted) > 0 && !wanted[repo.Owner] {
+			continue
+		}
+		res = append(res, repo)
+		byOwner[repo.Owner] = append(byOwner[repo.Owner], repo)
+	}
+
+	// the manifest lists all repositories of the configured owners, or of the
+	// owners it mentions if none are configured
+	for owner := range wanted {
+		if _, ok := byOwner[owner]; !ok {
+			byOwner[owner] = nil
+		}
+	}
+	for owner, repos := range byOwner {
+		c.record(owner, false, time.Time{}, repos, nil)
+	}
+
+	logPluginStatus(c.location, len(res))
+
+	return res.DeDuplicate(), nil
+}
+
+// read returns the contents of the manifest
+func (c *ManifestClient) read(ctx context.Context) ([]byte, error) {
+	if strings.HasPrefix(c.location, "http://") || strings.HasPrefix(c.location, "https://") {
+		body, _, err := get(ctx, c.client, c.location)
+		return body, err
+	}
+
+	return os.ReadFile(c.location)
+}
+
+// unmarshalManifest decodes a JSON or YAML manifest. JSON is decoded
+// separately, as YAML does not allow the tab indentation common in JSON.
+func unmarshalManifest(raw []byte, manifest *Manifest) error {
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{")) {
+		return json.Unmarshal(raw, manifest)
+	}
+
+	return yaml.Unmarshal(raw, manifest)
+}
@@ -0,0 +1,120 @@
+package upstream_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/wmalik/ogit/mock"
+	"github.com/wmalik/ogit/upstream"
+)
+
+var _ = Describe("Manifest repo", func() {
+	var repositories []upstream.HostRepository
+	var err error
+
+	Context("When the manifest is a YAML file", func() {
+		var dir string
+		BeforeEach(func() {
+			dir, err = os.MkdirTemp("", "ogit-manifest")
+			Expect(err).To(BeNil())
+
+			manifest := filepath.Join(dir, "repos.yaml")
+			Expect(os.WriteFile(manifest, []byte(`
+provider: git.example.com
+repositories:
+  - owner: infra
+    name: terraform
+    description: the infrastructure
+    sshCloneURL: alice@git.example.com:infra/terraform.git
+  - owner: platform
+    name: billing
+  - provider: legacy.example.com
+    owner: infra
+    name: scripts
+    httpsCloneURL: https://legacy.example.com/infra/scripts.git
+`), 0o644)).To(Succeed())
+
+			client := upstream.NewManifestClient(http.DefaultClient, manifest)
+			repositories, err = client.GetRepositories(context.Background(), []string{"infra"}, true)
+			Expect(err).To(BeNil())
+		})
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+		It("Returns the repositories of the configured owners", func() {
+			Expect(len(repositories)).To(Equal(2))
+			Expect(repositories[0].GetProvider()).To(Equal("git.example.com"))
+			Expect(repositories[0].GetOwner()).To(Equal("infra"))
+			Expect(repositories[0].GetName()).To(Equal("terraform"))
+			Expect(repositories[0].GetDescription()).To(Equal("the infrastructure"))
+			Expect(repositories[0].GetSSHCloneURL()).To(Equal("alice@git.example.com:infra/terraform.git"))
+			Expect(repositories[1].GetProvider()).To(Equal("legacy.example.com"))
+			Expect(repositories[1].GetName()).To(Equal("scripts"))
+			Expect(repositories[1].GetHTTPSCloneURL()).To(Equal("https://legacy.example.com/infra/scripts.git"))
+		})
+	})
+
+	Context("When the manifest is a JSON document at a URL", func() {
+		BeforeEach(func() {
+			httpClient := mock.NewHTTPClient().
+				Mock("GET", "/repos.json",
+					func(w http.ResponseWriter, r *http.Request) {
+						_, _ = w.Write([]byte(`
+							{
+							  "repositories": [
+								{"owner": "platform", "name": "billing", "sshCloneURL": "git@git.example.com:platform/billing.git"}
+							  ]
+							}`,
+						))
+					},
+				).Client()
+			client := upstream.NewManifestClient(httpClient, "https://config.example.com/repos.json")
+			repositories, err = client.GetRepositories(context.Background(), nil, false)
+			Expect(err).To(BeNil())
+		})
+		It("Returns all repositories of the manifest", func() {
+			Expect(len(repositories)).To(Equal(1))
+			Expect(repositories[0].GetProvider()).To(Equal("manifest"))
+			Expect(repositories[0].GetOwner()).To(Equal("platform"))
+			Expect(repositories[0].GetName()).To(Equal("billing"))
+			Expect(repositories[0].GetSSHCloneURL()).To(Equal("git@git.example.com:platform/billing.git"))
+		})
+	})
+
+	Context("When the manifest is invalid", func() {
+		var dir string
+		// getRepositories returns the error of reading manifest
+		getRepositories := func(manifest string) error {
+			path := filepath.Join(dir, "repos.yaml")
+			Expect(os.WriteFile(path, []byte(manifest), 0o644)).To(Succeed())
+
+			client := upstream.NewManifestClient(http.DefaultClient, path)
+			_, err := client.GetRepositories(context.Background(), nil, false)
+			return err
+		}
+
+		BeforeEach(func() {
+			dir, err = os.MkdirTemp("", "ogit-manifest")
+			Expect(err).To(BeNil())
+		})
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+		It("Rejects repositories without name", func() {
+			Expect(getRepositories("repositories: [{owner: infra}]")).To(MatchError(ContainSubstring("without owner or name")))
+		})
+		It("Rejects owners, names and providers which are not a single path segment", func() {
+			Expect(getRepositories("repositories: [{owner: .., name: terraform}]")).To(MatchError(ContainSubstring(`invalid owner ".."`)))
+			Expect(getRepositories("repositories: [{owner: infra, name: ../../.bashrc}]")).To(MatchError(ContainSubstring("invalid name")))
+			Expect(getRepositories("repositories: [{provider: /etc, owner: infra, name: terraform}]")).To(MatchError(ContainSubstring("invalid provider")))
+		})
+		It("Rejects the providers of the built-in clients", func() {
+			Expect(getRepositories("provider: github\nrepositories: [{owner: infra, name: terraform}]")).To(MatchError(ContainSubstring(`built-in provider "github"`)))
+		})
+	})
+})
@@ -18,6 +18,7 @@ type MockRepository struct {
 	CIURL                  string
 	ReleasesURL            string
 	SettingsURL            string
+	Metadata               Metadata
 }
 
 func (r *MockRepository) GetProvider() string {
@@ -72,8 +73,13 @@ func (r *MockRepository) GetSSHCloneURL() string {
 	return r.SSHCloneURL
 }
 
+func (r *MockRepository) GetMetadata() Metadata {
+	return r.Metadata
+}
+
 type MockClient struct {
 	repositories []MockRepository
+	err          error
 }
 
 func NewMockClient() *MockClient {
@@ -85,7 +91,17 @@ func (c *MockClient) WithRepositories(repos []MockRepository) *MockClient {
 	return c
 }
 
+// WithError makes GetRepositories fail with err
+func (c *MockClient) WithError(err error) *MockClient {
+	c.err = err
+	return c
+}
+
 func (c *MockClient) GetRepositories(ctx context.Context, owners []string, fetchAuthenticationUserRepos bool) ([]HostRepository, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
 	inputOwners := map[string]struct{}{}
 	for _, owner := range owners {
 		inputOwners[owner] = struct{}{}
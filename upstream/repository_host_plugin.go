@@ -0,0 +1,213 @@
+package upstream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// pluginPrefix is the prefix of the executables implementing external
+// providers, e.g. ogit-provider-catalog implements the catalog provider
+const pluginPrefix = "ogit-provider-"
+
+// builtinProviders are the providers under which the built-in clients store
+// repositories, which manifests and plugins may not claim
+var builtinProviders = map[string]bool{
+	"github":    true,
+	"gitlab":    true,
+	"bitbucket": true,
+	"sourcehut": true,
+	"local":     true,
+	"manifest":  true,
+}
+
+// PluginRequest is written as JSON to the stdin of a provider plugin
+type PluginRequest struct {
+	// the name of the config section of the provider e.g. catalog.work
+	Name string `json:"name"`
+	// the owners configured via orgs
+	Owners []string `json:"owners"`
+	// whether the repositories of the authenticated user should be returned
+	FetchUserRepos bool              `json:"fetchUserRepos"`
+	BaseURL        string            `json:"baseURL,omitempty"`
+	Token          string            `json:"token,omitempty"`
+	Settings       map[string]string `json:"settings,omitempty"`
+}
+
+// PluginResponse is read as JSON from the stdout of a provider plugin
+type PluginResponse struct {
+	Repositories []PluginRepository `json:"repositories"`
+}
+
+// PluginRepository is a repository returned by a provider plugin or listed
+// in a Manifest
+type PluginRepository struct {
+	// optional, defaults to the kind of the plugin (e.g. catalog) or to the
+	// provider of the manifest
+	Provider               string `json:"provider" yaml:"provider"`
+	Owner                  string `json:"owner" yaml:"owner"`
+	Name                   string `json:"name" yaml:"name"`
+	Description            string `json:"description" yaml:"description"`
+	BrowserHomepageURL     string `json:"browserHomepageURL" yaml:"browserHomepageURL"`
+	BrowserPullRequestsURL string `json:"browserPullRequestsURL" yaml:"browserPullRequestsURL"`
+	HTTPSCloneURL          string `json:"httpsCloneURL" yaml:"httpsCloneURL"`
+	SSHCloneURL            string `json:"sshCloneURL" yaml:"sshCloneURL"`
+	OrgURL                 string `json:"orgURL" yaml:"orgURL"`
+	IssuesURL              string `json:"issuesURL" yaml:"issuesURL"`
+	CIURL                  string `json:"ciURL" yaml:"ciURL"`
+	ReleasesURL            string `json:"releasesURL" yaml:"releasesURL"`
+	SettingsURL            string `json:"settingsURL" yaml:"settingsURL"`
+}
+
+func (r *PluginRepository) GetProvider() string {
+	return r.Provider
+}
+
+func (r *PluginRepository) GetName() string {
+	return r.Name
+}
+
+func (r *PluginRepository) GetOwner() string {
+	return r.Owner
+}
+
+func (r *PluginRepository) GetDescription() string {
+	return r.Description
+}
+
+func (r *PluginRepository) GetBrowserHomepageURL() string {
+	return r.BrowserHomepageURL
+}
+
+func (r *PluginRepository) GetBrowserPullRequestsURL() string {
+	return r.BrowserPullRequestsURL
+}
+
+func (r *PluginRepository) GetOrgURL() string {
+	return r.OrgURL
+}
+
+func (r *PluginRepository) GetIssuesURL() string {
+	return r.IssuesURL
+}
+
+func (r *PluginRepository) GetCIURL() string {
+	return r.CIURL
+}
+
+func (r *PluginRepository) GetReleasesURL() string {
+	return r.ReleasesURL
+}
+
+func (r *PluginRepository) GetSettingsURL() string {
+	return r.SettingsURL
+}
+
+func (r *PluginRepository) GetHTTPSCloneURL() string {
+	return r.HTTPSCloneURL
+}
+
+func (r *PluginRepository) GetSSHCloneURL() string {
+	return r.SSHCloneURL
+}
+
+// validate returns an error if the provider, owner or name of the repository
+// is not a single path segment, as they name the directory of its clone, or
+// if its provider is a built-in one other than ownProvider
+func (r *PluginRepository) validate(ownProvider string) error {
+	if r.Owner == "" || r.Name == "" {
+		return errors.New("repository without owner or name")
+	}
+
+	for _, segment := range []struct{ kind, value string }{
+		{"provider", r.Provider},
+		{"owner", r.Owner},
+		{"name", r.Name},
+	} {
+		if segment.value == "." || segment.value == ".." || strings.ContainsAny(segment.value, `/\`) || filepath.IsAbs(segment.value) {
+			return fmt.Errorf("repository with invalid %s %q", segment.kind, segment.value)
+		}
+	}
+
+	if r.Provider != ownProvider && builtinProviders[r.Provider] {
+		return fmt.Errorf("repository of the built-in provider %q", r.Provider)
+	}
+
+	return nil
+}
+
+// PluginClient fetches repositories by running an external executable, which
+// receives a PluginRequest on stdin and writes a PluginResponse to stdout
+type PluginClient struct {
+	executable string
+	kind       string
+	opts       ClientOptions
+}
+
+// NewPluginClient returns a client running executable to fetch the
+// repositories of the provider kind
+func NewPluginClient(executable string, kind string, opts ClientOptions) *PluginClient {
+	return &PluginClient{executable: executable, kind: kind, opts: opts}
+}
+
+// lookupPlugin returns a client for the ogit-provider-<kind> executable found in
+// PATH
+func lookupPlugin(kind string, opts ClientOptions) (*PluginClient, error) {
+	executable, err := exec.LookPath(pluginPrefix + kind)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported provider %q (no %s%s executable found in PATH)", kind, pluginPrefix, kind)
+	}
+
+	return NewPluginClient(executable, kind, opts), nil
+}
+
+func (c *PluginClient) GetRepositories(ctx context.Context, owners []string, fetchUserRepos bool) ([]HostRepository, error) {
+	request, err := json.Marshal(PluginRequest{
+		Name:           c.opts.Name,
+		Owners:         owners,
+		FetchUserRepos: fetchUserRepos,
+		BaseURL:        c.opts.BaseURL,
+		Token:          c.opts.Token,
+		Settings:       c.opts.Settings,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.executable)
+	cmd.Stdin = bytes.NewReader(request)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s failed: %w", c.executable, err)
+	}
+
+	var response PluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return nil, fmt.Errorf("%s returned an invalid response: %w", c.executable, err)
+	}
+
+	res := HostRepositories{}
+	for i := range response.Repositories {
+		repo := &response.Repositories[i]
+		if repo.Provider == "" {
+			repo.Provider = c.kind
+		}
+		if err := repo.validate(c.kind); err != nil {
+			return nil, fmt.Errorf("%s returned a %w", c.executable, err)
+		}
+		res = append(res, repo)
+	}
+
+	logPluginStatus(c.executable, len(res))
+
+	return res.DeDuplicate(), nil
+}
@@ -0,0 +1,138 @@
+package upstream_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/wmalik/ogit/upstream"
+)
+
+var _ = Describe("Plugin repo", func() {
+	var dir string
+	var client *upstream.PluginClient
+	var repositories []upstream.HostRepository
+	var err error
+
+	// writePlugin writes an executable which stores its stdin in request.json
+	// and writes response to stdout
+	writePlugin := func(response string, exitCode int) string {
+		executable := filepath.Join(dir, "ogit-provider-catalog")
+		script := "#!/bin/sh\n" +
+			"cat > " + filepath.Join(dir, "request.json") + "\n" +
+			"cat <<'EOF'\n" + response + "\nEOF\n" +
+			"exit " + strconv.Itoa(exitCode) + "\n"
+		Expect(os.WriteFile(executable, []byte(script), 0o755)).To(Succeed())
+		return executable
+	}
+
+	BeforeEach(func() {
+		dir, err = os.MkdirTemp("", "ogit-plugin")
+		Expect(err).To(BeNil())
+	})
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Context("When the plugin succeeds", func() {
+		BeforeEach(func() {
+			executable := writePlugin(`{
+				"repositories": [
+					{
+						"owner": "platform",
+						"name": "billing",
+						"description": "the billing service",
+						"browserHomepageURL": "https://catalog.example.com/platform/billing",
+						"sshCloneURL": "git@git.example.com:platform/billing.git"
+					},
+					{
+						"provider": "git.example.com",
+						"owner": "platform",
+						"name": "payments",
+						"httpsCloneURL": "https://git.example.com/platform/payments.git"
+					}
+				]
+			}`, 0)
+			client = upstream.NewPluginClient(executable, "catalog", upstream.ClientOptions{
+				Name:     "catalog.work",
+				Token:    "sometoken",
+				Settings: map[string]string{"endpoint": "https://catalog.example.com"},
+			})
+			repositories, err = client.GetRepositories(context.Background(), []string{"platform"}, true)
+			Expect(err).To(BeNil())
+		})
+		It("Passes the configured owners and settings to the plugin", func() {
+			raw, err := os.ReadFile(filepath.Join(dir, "request.json"))
+			Expect(err).To(BeNil())
+
+			var request upstream.PluginRequest
+			Expect(json.Unmarshal(raw, &request)).To(Succeed())
+			Expect(request.Name).To(Equal("catalog.work"))
+			Expect(request.Owners).To(Equal([]string{"platform"}))
+			Expect(request.FetchUserRepos).To(BeTrue())
+			Expect(request.Token).To(Equal("sometoken"))
+			Expect(request.Settings).To(Equal(map[string]string{"endpoint": "https://catalog.example.com"}))
+		})
+		It("Returns the repositories of the plugin", func() {
+			Expect(len(repositories)).To(Equal(2))
+			Expect(repositories[0].GetProvider()).To(Equal("catalog"))
+			Expect(repositories[0].GetOwner()).To(Equal("platform"))
+			Expect(repositories[0].GetName()).To(Equal("billing"))
+			Expect(repositories[0].GetDescription()).To(Equal("the billing service"))
+			Expect(repositories[0].GetBrowserHomepageURL()).To(Equal("https://catalog.example.com/platform/billing"))
+			Expect(repositories[0].GetSSHCloneURL()).To(Equal("git@git.example.com:platform/billing.git"))
+			Expect(repositories[1].GetProvider()).To(Equal("git.example.com"))
+			Expect(repositories[1].GetName()).To(Equal("payments"))
+			Expect(repositories[1].GetHTTPSCloneURL()).To(Equal("https://git.example.com/platform/payments.git"))
+		})
+	})
+
+	Context("When the plugin fails", func() {
+		It("Returns an error", func() {
+			client = upstream.NewPluginClient(writePlugin(`{}`, 1), "catalog", upstream.ClientOptions{})
+			_, err = client.GetRepositories(context.Background(), []string{"platform"}, false)
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Context("When the plugin returns a repository without name", func() {
+		It("Returns an error", func() {
+			client = upstream.NewPluginClient(writePlugin(`{"repositories": [{"owner": "platform"}]}`, 0), "catalog", upstream.ClientOptions{})
+			_, err = client.GetRepositories(context.Background(), []string{"platform"}, false)
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Context("When the plugin returns a repository escaping the clone directory", func() {
+		It("Returns an error", func() {
+			client = upstream.NewPluginClient(writePlugin(`{"repositories": [{"owner": "platform", "name": "../../.ssh"}]}`, 0), "catalog", upstream.ClientOptions{})
+			_, err = client.GetRepositories(context.Background(), []string{"platform"}, false)
+			Expect(err).To(MatchError(ContainSubstring(`invalid name "../../.ssh"`)))
+		})
+	})
+
+	Context("When the plugin returns a repository of a built-in provider", func() {
+		It("Returns an error", func() {
+			client = upstream.NewPluginClient(writePlugin(`{"repositories": [{"provider": "github", "owner": "platform", "name": "billing"}]}`, 0), "catalog", upstream.ClientOptions{})
+			_, err = client.GetRepositories(context.Background(), []string{"platform"}, false)
+			Expect(err).To(MatchError(ContainSubstring(`built-in provider "github"`)))
+		})
+	})
+
+	Context("When no plugin is found for a provider", func() {
+		It("Returns an error", func() {
+			_, err = upstream.NewClient("doesnotexist", upstream.ClientOptions{})
+			Expect(err).To(MatchError(ContainSubstring("ogit-provider-doesnotexist")))
+		})
+		It("Can be registered as a provider failing with the error", func() {
+			_, err = upstream.NewClient("doesnotexist", upstream.ClientOptions{})
+			_, fetchErr := upstream.NewUnavailableClient(err).GetRepositories(context.Background(), nil, true)
+			Expect(fetchErr).To(MatchError(err))
+		})
+	})
+})
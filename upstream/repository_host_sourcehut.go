@@ -0,0 +1,264 @@
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const sourcehutHost = "sr.ht"
+const sourcehutGraphQLURL = "https://git.sr.ht/query"
+
+// sourcehutRepositoriesFields are the fields of the repositories queried from
+// the GraphQL API of git.sr.ht
+const sourcehutRepositoriesFields = `
+	canonicalName
+	repositories(cursor: $cursor) {
+		results { name description visibility }
+		cursor
+	}`
+
+const sourcehutUserQuery = `query($username: String!, $cursor: Cursor) {
+	user(username: $username) {` + sourcehutRepositoriesFields + `
+	}
+}`
+
+const sourcehutMeQuery = `query($cursor: Cursor) {
+	me {` + sourcehutRepositoriesFields + `
+	}
+}`
+
+// SourcehutRepository is a repository as returned by the GraphQL API of
+// git.sr.ht
+type SourcehutRepository struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Visibility  string `json:"visibility"`
+
+	// the canonical name of the owner e.g. ~sircmpwn
+	owner string
+}
+
+func (r *SourcehutRepository) GetProvider() string {
+	return "sourcehut"
+}
+
+func (r *SourcehutRepository) GetName() string {
+	return r.Name
+}
+
+// GetOwner returns the name of the owner without the ~ prefix
+func (r *SourcehutRepository) GetOwner() string {
+	return strings.TrimPrefix(r.owner, "~")
+}
+
+func (r *SourcehutRepository) GetDescription() string {
+	return r.Description
+}
+
+func (r *SourcehutRepository) GetBrowserHomepageURL() string {
+	return r.url("git")
+}
+
+// GetBrowserPullRequestsURL returns the URL of the development mailing list,
+// which by convention is named <repository>-devel
+func (r *SourcehutRepository) GetBrowserPullRequestsURL() string {
+	return r.url("lists") + "-devel"
+}
+
+func (r *SourcehutRepository) GetOrgURL() string {
+	return "https://" + sourcehutHost + "/" + r.owner
+}
+
+func (r *SourcehutRepository) GetIssuesURL() string {
+	return r.url("todo")
+}
+
+func (r *SourcehutRepository) GetCIURL() string {
+	return r.url("builds")
+}
+
+func (r *SourcehutRepository) GetReleasesURL() string {
+	return r.url("git") + "/refs"
+}
+
+func (r *SourcehutRepository) GetSettingsURL() string {
+	return r.url("git") + "/settings/info"
+}
+
+func (r *SourcehutRepository) GetHTTPSCloneURL() string {
+	return r.url("git")
+}
+
+func (r *SourcehutRepository) GetSSHCloneURL() string {
+	return fmt.Sprintf("git@git.%s:%s/%s", sourcehutHost, r.owner, r.Name)
+}
+
+// url returns the URL of the repository on the given sr.ht service e.g. todo
+func (r *SourcehutRepository) url(service string) string {
+	return fmt.Sprintf("https://%s.%s/%s/%s", service, sourcehutHost, r.owner, r.Name)
+}
+
+// sourcehutOwner is the user (or authenticated user) returned by a query
+type sourcehutOwner struct {
+	CanonicalName string `json:"canonicalName"`
+	Repositories  struct {
+		Results []*SourcehutRepository `json:"results"`
+		Cursor  *string                `json:"cursor"`
+	} `json:"repositories"`
+}
+
+// sourcehutResponse is the response of sourcehutUserQuery and sourcehutMeQuery
+type sourcehutResponse struct {
+	Data struct {
+		User *sourcehutOwner `json:"user"`
+		Me   *sourcehutOwner `json:"me"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// SourcehutClient fetches repositories from git.sr.ht
+type SourcehutClient struct {
+	fetchResults
+	client *http.Client
+	url    string
+	// the name of the authenticated user without ~, once its repositories
+	// were fetched
+	username string
+}
+
+func NewSourcehutClient(client *http.Client) *SourcehutClient {
+	return &SourcehutClient{client: client, url: sourcehutGraphQLURL}
+}
+
+// NewSourcehutClientWithToken returns a client authenticated with a personal
+// access token. The API of sr.ht does not support anonymous access.
+func NewSourcehutClientWithToken(token string) *SourcehutClient {
+	if token == "" {
+		log.Println("sourcehut token is not set, fetching repositories will fail")
+		return NewSourcehutClient(http.DefaultClient)
+	}
+
+	return NewSourcehutClient(
+		oauth2.NewClient(
+			context.Background(),
+			oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}),
+		),
+	)
+}
+
+// GetRepositories returns the repositories of the given users, which may be
+// prefixed with ~. When fetching the repositories of some users fails, the
+// repositories of the other users are returned along with a PartialError.
+func (c *SourcehutClient) GetRepositories(ctx context.Context, users []string, fetchUserRepos bool) ([]HostRepository, error) {
+	res := HostRepositories{}
+	var m sync.Map
+
+	c.reset()
+
+	var wg sync.WaitGroup
+	if fetchUserRepos {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			repos, err := c.getRepositories(ctx, sourcehutMeQuery, "")
+			c.record(c.username, false, time.Time{}, repos, err)
+			if err != nil {
+				return
+			}
+
+			m.Store("", repos)
+		}()
+	}
+
+	for _, user := range users {
+		wg.Add(1)
+		go func(user string) {
+			defer wg.Done()
+			username := strings.TrimPrefix(user, "~")
+			repos, err := c.getRepositories(ctx, sourcehutUserQuery, username)
+			c.record(username, false, time.Time{}, repos, err)
+			if err != nil {
+				return
+			}
+
+			m.Store(user, repos)
+		}(user)
+	}
+
+	wg.Wait()
+
+	m.Range(func(key, value interface{}) bool {
+		res = append(res, value.([]HostRepository)...)
+		return true
+	})
+
+	return res.DeDuplicate(), c.err()
+}
+
+// getRepositories fetches all pages of the repositories of username, or of
+// the authenticated user if username is empty
+func (c *SourcehutClient) getRepositories(ctx context.Context, query string, username string) ([]HostRepository, error) {
+	var reposAcc []*SourcehutRepository
+	var cursor *string
+	for {
+		variables := map[string]interface{}{"cursor": cursor}
+		if username != "" {
+			variables["username"] = username
+		}
+
+		var resp sourcehutResponse
+		if err := postJSON(ctx, c.client, c.url, map[string]interface{}{
+			"query":     query,
+			"variables": variables,
+		}, &resp); err != nil {
+			return nil, err
+		}
+
+		if len(resp.Errors) > 0 {
+			return nil, fmt.Errorf("sourcehut: %s", resp.Errors[0].Message)
+		}
+
+		owner := resp.Data.User
+		if username == "" {
+			owner = resp.Data.Me
+		}
+		if owner == nil {
+			return nil, fmt.Errorf("sourcehut: user %q %w", username, errNotFound)
+		}
+		if username == "" {
+			c.username = strings.TrimPrefix(owner.CanonicalName, "~")
+		}
+
+		for _, r := range owner.Repositories.Results {
+			r.owner = owner.CanonicalName
+		}
+		reposAcc = append(reposAcc, owner.Repositories.Results...)
+
+		// the API does not return the number of results, only whether there
+		// are more pages
+		cursor = owner.Repositories.Cursor
+		remainingPages := 0
+		if cursor != nil {
+			remainingPages = 1
+		}
+		logPaginationStatus(sourcehutHost, owner.CanonicalName, len(owner.Repositories.Results), remainingPages, "n/a")
+		if cursor == nil {
+			break
+		}
+	}
+
+	repos := make([]HostRepository, len(reposAcc))
+	for i, r := range reposAcc {
+		repos[i] = r
+	}
+	return repos, nil
+}
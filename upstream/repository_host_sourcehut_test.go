@@ -0,0 +1,121 @@
+package upstream_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/wmalik/ogit/mock"
+	"github.com/wmalik/ogit/upstream"
+)
+
+var _ = Describe("Sourcehut repo", func() {
+	var client *upstream.SourcehutClient
+	var repositories []upstream.HostRepository
+	var username interface{}
+	var err error
+	BeforeEach(func() {
+		httpClient := mock.NewHTTPClient().
+			Mock("POST", "/query",
+				func(w http.ResponseWriter, r *http.Request) {
+					var body struct {
+						Variables map[string]interface{} `json:"variables"`
+					}
+					_ = json.NewDecoder(r.Body).Decode(&body)
+					username = body.Variables["username"]
+
+					if body.Variables["cursor"] == "page2" {
+						_, _ = w.Write([]byte(`
+							{"data": {"user": {
+							  "canonicalName": "~greatuser",
+							  "repositories": {
+								"results": [{"name": "personal-website", "description": "my personal website"}],
+								"cursor": null
+							  }
+							}}}`,
+						))
+						return
+					}
+					_, _ = w.Write([]byte(`
+						{"data": {"user": {
+						  "canonicalName": "~greatuser",
+						  "repositories": {
+							"results": [{"name": "dotfiles", "description": "my dotfiles"}],
+							"cursor": "page2"
+						  }
+						}}}`,
+					))
+				},
+			).Client()
+		client = upstream.NewSourcehutClient(httpClient)
+		repositories, err = client.GetRepositories(context.Background(), []string{"~greatuser"}, false)
+		Expect(err).To(BeNil())
+	})
+	It("Returns the repositories of all pages of a user", func() {
+		Expect(username).To(Equal("greatuser"))
+		Expect(len(repositories)).To(Equal(2))
+		Expect(repositories[0].GetName()).To(Equal("dotfiles"))
+		Expect(repositories[1].GetName()).To(Equal("personal-website"))
+		Expect(repositories[1].GetDescription()).To(Equal("my personal website"))
+	})
+	It("Returns the attributes of a repository", func() {
+		Expect(repositories[0].GetProvider()).To(Equal("sourcehut"))
+		Expect(repositories[0].GetOwner()).To(Equal("greatuser"))
+		Expect(repositories[0].GetDescription()).To(Equal("my dotfiles"))
+		Expect(repositories[0].GetBrowserHomepageURL()).To(Equal("https://git.sr.ht/~greatuser/dotfiles"))
+		Expect(repositories[0].GetBrowserPullRequestsURL()).To(Equal("https://lists.sr.ht/~greatuser/dotfiles-devel"))
+		Expect(repositories[0].GetOrgURL()).To(Equal("https://sr.ht/~greatuser"))
+		Expect(repositories[0].GetIssuesURL()).To(Equal("https://todo.sr.ht/~greatuser/dotfiles"))
+		Expect(repositories[0].GetCIURL()).To(Equal("https://builds.sr.ht/~greatuser/dotfiles"))
+		Expect(repositories[0].GetReleasesURL()).To(Equal("https://git.sr.ht/~greatuser/dotfiles/refs"))
+		Expect(repositories[0].GetSettingsURL()).To(Equal("https://git.sr.ht/~greatuser/dotfiles/settings/info"))
+		Expect(repositories[0].GetHTTPSCloneURL()).To(Equal("https://git.sr.ht/~greatuser/dotfiles"))
+		Expect(repositories[0].GetSSHCloneURL()).To(Equal("git@git.sr.ht:~greatuser/dotfiles"))
+	})
+})
+
+var _ = Describe("Sourcehut repo with an unknown user", func() {
+	var client *upstream.SourcehutClient
+	var repositories []upstream.HostRepository
+	var err error
+	BeforeEach(func() {
+		httpClient := mock.NewHTTPClient().
+			Mock("POST", "/query",
+				func(w http.ResponseWriter, r *http.Request) {
+					var body struct {
+						Variables map[string]interface{} `json:"variables"`
+					}
+					_ = json.NewDecoder(r.Body).Decode(&body)
+					if body.Variables["username"] == "ghost" {
+						_, _ = w.Write([]byte(`{"data": {"user": null}}`))
+						return
+					}
+					_, _ = w.Write([]byte(`
+						{"data": {"me": {
+						  "canonicalName": "~greatuser",
+						  "repositories": {"results": [{"name": "dotfiles"}], "cursor": null}
+						}}}`,
+					))
+				},
+			).Client()
+		client = upstream.NewSourcehutClient(httpClient)
+		repositories, err = client.GetRepositories(context.Background(), []string{"~ghost"}, true)
+	})
+	It("Returns the repositories of the other users along with the failure", func() {
+		var partial *upstream.PartialError
+		Expect(errors.As(err, &partial)).To(BeTrue())
+		Expect(partial.Failed).To(HaveLen(1))
+		Expect(partial.Failed[0].Owner).To(Equal("ghost"))
+		Expect(len(repositories)).To(Equal(1))
+		Expect(repositories[0].GetOwner()).To(Equal("greatuser"))
+
+		results := client.FetchResults()
+		Expect(results).To(HaveLen(2))
+		Expect(results[1].Owner).To(Equal("greatuser"))
+		Expect(results[1].Complete).To(BeTrue())
+	})
+})